@@ -0,0 +1,89 @@
+package sirdsc
+
+import (
+	"context"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"math"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// GenerateFrames generates an animated stereogram with a frame for
+// each of dms. Every frame is generated with the same pattern and
+// options, so rows whose depths are the same in every frame are drawn
+// the same way in every frame, too.
+//
+// The frames are drawn with the palette.Plan9 palette so that they can
+// be encoded as a GIF, for example with NewGIF. The bounds of each
+// frame are opts.OutputBounds of the bounds of its depth map.
+//
+// The frames are generated concurrently. All of them run their rows on
+// opts.Pool, or, if it is nil, on a single pool of opts.Workers
+// workers, so generating them together doesn't use any more workers
+// than generating one of them would. If generating any of them fails,
+// the others are canceled and the first error is returned.
+func GenerateFrames(ctx context.Context, dms []DepthMap, pat image.Image, opts *Options) ([]*image.Paletted, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	for _, dm := range dms {
+		if dm == nil {
+			return nil, ErrNoDepthMap
+		}
+	}
+
+	shared := *opts
+	if shared.Pool == nil {
+		shared.Pool = NewPool(opts.Workers)
+		defer shared.Pool.Close()
+	}
+
+	frames := make([]*image.Paletted, len(dms))
+	eg, ctx := errgroup.WithContext(ctx)
+	for i, dm := range dms {
+		eg.Go(func() error {
+			out := image.NewPaletted(shared.OutputBounds(dm.Bounds(), pat), palette.Plan9)
+			err := GenerateContext(ctx, out, dm, pat, &shared)
+			if err != nil {
+				return err
+			}
+			frames[i] = out
+			return nil
+		})
+	}
+
+	err := eg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// NewGIF returns an animated GIF that shows frames at fps frames per
+// second and loops forever. Its size is large enough to fit all of the
+// frames.
+//
+// GIF delays are in hundredths of a second, so fps is rounded to the
+// nearest delay. Many viewers treat delays of less than two hundredths
+// of a second as much longer ones, so the delay is never less than
+// that.
+func NewGIF(frames []*image.Paletted, fps float64) *gif.GIF {
+	delay := 10
+	if fps > 0 {
+		delay = max(int(math.Round(100/fps)), 2)
+	}
+
+	g := gif.GIF{
+		Image: frames,
+		Delay: make([]int, len(frames)),
+	}
+	var r image.Rectangle
+	for i, frame := range frames {
+		g.Delay[i] = delay
+		r = r.Union(frame.Rect)
+	}
+	g.Config.Width, g.Config.Height = r.Max.X, r.Max.Y
+	return &g
+}
@@ -0,0 +1,104 @@
+package sirdsc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestGenerateFrames(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 100)
+	var dms []sirdsc.DepthMap
+	for i := range 3 {
+		box := sirdsc.NewDepth(image.Rect(100+10*i, 40, 150+10*i, 60))
+		box.Fill(box.Rect, 20)
+		dms = append(dms, sirdsc.Max(sirdsc.NewDepth(bounds), box))
+	}
+
+	pat := sirdsc.RandImage{Seed: 1}
+	opts := &sirdsc.Options{PartSize: 50}
+	frames, err := sirdsc.GenerateFrames(context.Background(), dms, pat, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != len(dms) {
+		t.Fatalf("%v frames", len(frames))
+	}
+
+	// Rows that don't go through the box should be the same in every
+	// frame, and the rows that do should change.
+	row := func(img *image.Paletted, y int) []byte {
+		return img.Pix[img.PixOffset(img.Rect.Min.X, y):img.PixOffset(img.Rect.Max.X, y)]
+	}
+	for _, frame := range frames[1:] {
+		if frame.Rect != opts.OutputBounds(bounds, pat) {
+			t.Fatalf("frame bounds: %v", frame.Rect)
+		}
+		if !bytes.Equal(row(frame, 10), row(frames[0], 10)) {
+			t.Fatal("background changed between frames")
+		}
+		if bytes.Equal(row(frame, 50), row(frames[0], 50)) {
+			t.Fatal("box didn't change between frames")
+		}
+	}
+
+	g := sirdsc.NewGIF(frames, 25)
+	if (g.Config.Width != 350) || (g.Config.Height != 100) {
+		t.Fatalf("GIF size: %vx%v", g.Config.Width, g.Config.Height)
+	}
+	for _, delay := range g.Delay {
+		if delay != 4 {
+			t.Fatalf("delay: %v", delay)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateFramesPool(t *testing.T) {
+	var dms []sirdsc.DepthMap
+	for i := range 8 {
+		d := sirdsc.NewDepth(image.Rect(0, 0, 200, 80))
+		d.Fill(image.Rect(20+10*i, 20, 80+10*i, 60), 15)
+		dms = append(dms, d)
+	}
+
+	pool := sirdsc.NewPool(2)
+	defer pool.Close()
+
+	pat := sirdsc.RandImage{Seed: 2}
+	opts := &sirdsc.Options{PartSize: 40, Pool: pool}
+	frames, err := sirdsc.GenerateFrames(context.Background(), dms, pat, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generating the frames together should give the same results as
+	// generating them one at a time.
+	for i, dm := range dms {
+		out := image.NewPaletted(frames[i].Rect, frames[i].Palette)
+		err := sirdsc.GenerateContext(context.Background(), out, dm, pat, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Pix, frames[i].Pix) {
+			t.Fatalf("frame %v is different", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sirdsc.GenerateFrames(ctx, dms, pat, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled generation returned %v", err)
+	}
+}
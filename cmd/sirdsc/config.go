@@ -0,0 +1,698 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/dem"
+	"github.com/DeedleFake/sirdsc/mesh"
+	"github.com/DeedleFake/sirdsc/sdf"
+)
+
+// config holds the values of the command-line flags.
+type config struct {
+	partSize    int
+	maxDepth    int
+	flat        bool
+	inverse     bool
+	signed      bool
+	channel     string
+	alphaMask   bool
+	autoLevels  bool
+	equalize    bool
+	curve       string
+	gamma       float64
+	posterize   int
+	seed        uint64
+	sym         bool
+	hsr         bool
+	cross       bool
+	dpi         float64
+	distance    float64
+	eyeSep      float64
+	farPlane    float64
+	mu          float64
+	center      bool
+	framing     string
+	oversample  int
+	shape       string
+	terrain     bool
+	octaves     int
+	roughness   float64
+	seaLevel    float64
+	island      float64
+	expr        string
+	text        string
+	fontFile    string
+	textSize    float64
+	align       string
+	lineSpacing float64
+	bevel       float64
+	round       bool
+	rotate      string
+	zoom        float64
+	perspective bool
+	fov         float64
+	terrainRGB  bool
+	exaggerate  float64
+	elevation   string
+	splat       float64
+	fill        int
+	frames      int
+	fps         float64
+	axis        string
+	path        string
+	patFile     string
+	width       int
+	height      int
+	filter      string
+	fit         string
+	outFile     string
+	depthFile   string
+}
+
+// parseFlags parses the command-line flags into a config and returns
+// it along with the src argument, which is empty if there isn't one.
+func parseFlags() (c *config, inFile string) {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v [options] [src]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	c = new(config)
+	flag.IntVar(&c.partSize, "partsize", 100, "Size of sections in the SIRDS")
+	flag.IntVar(&c.maxDepth, "depth", sirdsc.DefaultMaxImageDepth, "Maximum depth")
+	flag.BoolVar(&c.flat, "flat", false, "Generate an image with only two planes")
+	flag.BoolVar(&c.inverse, "inverse", false, "Treat darker pixels as closer in the depth map")
+	flag.BoolVar(&c.signed, "signed", false, "Treat mid-gray as the background in the depth map, allowing darker pixels to be behind it")
+	flag.StringVar(&c.channel, "channel", "max", "Channel of the depth map to read depths from: max, luma, red, green, blue, or alpha")
+	flag.BoolVar(&c.alphaMask, "alphamask", false, "Treat transparent pixels in the depth map as the background")
+	flag.BoolVar(&c.autoLevels, "autolevels", false, "Stretch the range of values in the depth map to the full range of depths")
+	flag.BoolVar(&c.equalize, "equalize", false, "Equalize the histogram of the depth map")
+	flag.StringVar(&c.curve, "curve", "", "Piecewise-linear curve to apply to the depth map, as a comma-separated list of in:out pairs in the range [0, 1]")
+	flag.Float64Var(&c.gamma, "gamma", 1, "Gamma correction to apply to the depth map")
+	flag.IntVar(&c.posterize, "posterize", 0, "If at least 2, reduce the depth map to this many evenly spaced layers")
+	flag.Uint64Var(&c.seed, "seed", uint64(time.Now().UnixNano()), "Color generation seed, which is also used to generate -terrain and the noise of -expr")
+	flag.BoolVar(&c.sym, "sym", false, "Use symmetric generation")
+	flag.BoolVar(&c.hsr, "hsr", false, "Use hidden surface removal")
+	flag.BoolVar(&c.cross, "cross", false, "Generate a cross-eyed stereogram instead of a wall-eyed one")
+	flag.Float64Var(&c.dpi, "dpi", 0, "If not zero, calculate separations from a physical viewing geometry for a display with this resolution")
+	flag.Float64Var(&c.distance, "distance", sirdsc.DefaultDistance, "Viewing distance in inches when using -dpi")
+	flag.Float64Var(&c.eyeSep, "eyesep", sirdsc.DefaultEyeSeparation, "Eye separation in inches when using -dpi")
+	flag.Float64Var(&c.farPlane, "farplane", 0, "Distance of the background behind the screen in inches when using -dpi, or the viewing distance if zero")
+	flag.Float64Var(&c.mu, "mu", sirdsc.DefaultMu, "Depth of field as a fraction of the distance to the background when using -dpi")
+	flag.BoolVar(&c.center, "center", false, "Propagate outwards from the center instead of from the left edge")
+	flag.StringVar(&c.framing, "framing", "extra", "Output framing: extra (an extra strip on the left), crop (the size of the depth map), or center (half a strip on each side)")
+	flag.IntVar(&c.oversample, "oversample", 1, "Number of samples per pixel, for smoother surfaces")
+	flag.StringVar(&c.shape, "shape", "", "If not empty, generate a stereogram of a shape instead of reading a depth map: sphere, cone, cylinder, torus, box, plane, ramp, waves, ripples, or annulus")
+	flag.BoolVar(&c.terrain, "terrain", false, "Generate a stereogram of procedurally generated terrain instead of reading a depth map")
+	flag.IntVar(&c.octaves, "octaves", sirdsc.DefaultTerrainOctaves, "Number of levels of detail of -terrain")
+	flag.Float64Var(&c.roughness, "roughness", sirdsc.DefaultTerrainRoughness, "Roughness of -terrain, from 0 for smooth to 1 for jagged")
+	flag.Float64Var(&c.seaLevel, "sealevel", 0, "Fraction of -terrain that is under water")
+	flag.Float64Var(&c.island, "island", 0, "Strength of a falloff from 0 to 1 that lowers the edges of -terrain to make an island")
+	flag.StringVar(&c.expr, "expr", "", "If not empty, generate a stereogram of the depths calculated by this expression of x, y, w, h, and t instead of reading a depth map")
+	flag.StringVar(&c.text, "text", "", "If not empty, generate a stereogram of this text instead of reading a depth map")
+	flag.StringVar(&c.fontFile, "font", "", "TrueType or OpenType font to render -text with, instead of a simple built-in bitmap font")
+	flag.Float64Var(&c.textSize, "textsize", 96, "Size of -text in pixels")
+	flag.StringVar(&c.align, "align", "left", "Alignment of the lines of -text: left, center, or right")
+	flag.Float64Var(&c.lineSpacing, "linespacing", 1, "Distance between lines of -text as a multiple of the font's line height")
+	flag.Float64Var(&c.bevel, "bevel", 0, "Width in pixels of the slopes along the edges of the letters of -text")
+	flag.BoolVar(&c.round, "round", false, "Round the edges of the letters of -text off")
+	flag.StringVar(&c.rotate, "rotate", "", "Rotation of a mesh, point cloud, or scene src in degrees around the X, Y, and Z axes, in the form x,y,z")
+	flag.Float64Var(&c.zoom, "zoom", 1, "Zoom of the camera for a mesh, point cloud, or scene src")
+	flag.BoolVar(&c.perspective, "perspective", false, "View a mesh, point cloud, or scene src with a perspective projection instead of an orthographic one")
+	flag.Float64Var(&c.fov, "fov", 45, "Field of view of the camera in degrees when using -perspective")
+	flag.BoolVar(&c.terrainRGB, "terrainrgb", false, "Read src as a Terrain-RGB elevation image, even if its extension isn't .pngraw")
+	flag.Float64Var(&c.exaggerate, "exaggerate", 1, "Vertical exaggeration of an elevation model src")
+	flag.StringVar(&c.elevation, "elevation", "", "Range of elevations of an elevation model src to map to depths, in the form low,high, clamping elevations outside of it, or the range of the src if empty")
+	flag.Float64Var(&c.splat, "splat", 1, "Radius in pixels of the points of a point cloud src")
+	flag.IntVar(&c.fill, "fill", 2, "Size in pixels of the gaps between the points of a point cloud src to fill in")
+	flag.IntVar(&c.frames, "frames", 0, "If not zero, generate an animated GIF with this many frames of a mesh, point cloud, or scene src, spinning it around -axis or following -path, or of -expr, with t counting the seconds since the first frame")
+	flag.Float64Var(&c.fps, "fps", 15, "Frames per second of an animation")
+	flag.StringVar(&c.axis, "axis", "0,1,0", "Axis that a mesh, point cloud, or scene src spins around in an animation, in the form x,y,z")
+	flag.StringVar(&c.path, "path", "", "If not empty, animate a mesh, point cloud, or scene src by moving the camera along this path instead of spinning it, in the form x,y,z@zoom;x,y,z@zoom;... where each x,y,z is a rotation like -rotate and each @zoom is optional")
+	flag.StringVar(&c.patFile, "pat", "", "If not empty, use the specified file as the pattern instead of randomizing")
+	flag.IntVar(&c.width, "width", 0, "If not zero, resample the depth map to this width, calculating the height from the aspect ratio if -height is zero, or, with -shape, -terrain, -expr, or a mesh, point cloud, or scene src, the width of the depth map (default 800)")
+	flag.IntVar(&c.height, "height", 0, "If not zero, resample the depth map to this height, calculating the width from the aspect ratio if -width is zero, or, with -shape, -terrain, -expr, or a mesh, point cloud, or scene src, the height of the depth map (default 600)")
+	flag.StringVar(&c.filter, "filter", "bilinear", "Filter to resample the depth map with: nearest, bilinear, or bicubic")
+	flag.StringVar(&c.fit, "fit", "fit", "How to resample the depth map to a different aspect ratio: fit, fill, or stretch")
+	flag.StringVar(&c.outFile, "o", "", "Output file")
+	flag.StringVar(&c.depthFile, "depthout", "", "If not empty, also save the depth map to the specified file as a PFM")
+	flag.Parse()
+
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		inFile = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return c, inFile
+}
+
+// usageError is an error caused by invalid flags or arguments, rather
+// than by something going wrong while generating the stereogram.
+type usageError struct {
+	error
+}
+
+// usagef returns a usageError with the formatted message.
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exit prints err and exits with a status of 2 if it is a usageError
+// or 1 otherwise.
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	if errors.As(err, new(usageError)) {
+		os.Exit(2)
+	}
+	os.Exit(1)
+}
+
+// sourceKind is a kind of source that a depth map can be made from.
+type sourceKind int
+
+const (
+	sourceImage sourceKind = iota
+	sourcePFM
+	sourceShape
+	sourceTerrain
+	sourceExpr
+	sourceText
+	sourceDEM
+	sourceMesh
+	sourcePointCloud
+	sourceScene
+)
+
+func (k sourceKind) String() string {
+	switch k {
+	case sourceImage:
+		return "an image"
+	case sourcePFM:
+		return "a PFM depth map"
+	case sourceShape:
+		return "a shape"
+	case sourceTerrain:
+		return "terrain"
+	case sourceExpr:
+		return "an expression"
+	case sourceText:
+		return "text"
+	case sourceDEM:
+		return "an elevation model"
+	case sourceMesh:
+		return "a mesh"
+	case sourcePointCloud:
+		return "a point cloud"
+	case sourceScene:
+		return "a scene"
+	default:
+		return fmt.Sprintf("sourceKind(%d)", int(k))
+	}
+}
+
+// rendered returns true if depth maps of the kind are rendered at the
+// requested size instead of being resampled to it.
+func (k sourceKind) rendered() bool {
+	switch k {
+	case sourceImage, sourcePFM, sourceText, sourceDEM:
+		return false
+	default:
+		return true
+	}
+}
+
+var (
+	cameraSources   = []sourceKind{sourceMesh, sourcePointCloud, sourceScene}
+	animatedSources = []sourceKind{sourceExpr, sourceMesh, sourcePointCloud, sourceScene}
+	resampleSources = []sourceKind{sourceImage, sourcePFM, sourceText, sourceDEM}
+)
+
+// sourceFlags are the flags that only apply to some kinds of sources,
+// and the kinds that they apply to.
+var sourceFlags = map[string][]sourceKind{
+	"flat":        {sourceImage},
+	"inverse":     {sourceImage},
+	"signed":      {sourceImage},
+	"channel":     {sourceImage},
+	"alphamask":   {sourceImage},
+	"autolevels":  {sourceImage},
+	"equalize":    {sourceImage},
+	"curve":       {sourceImage},
+	"gamma":       {sourceImage},
+	"posterize":   {sourceImage},
+	"octaves":     {sourceTerrain},
+	"roughness":   {sourceTerrain},
+	"sealevel":    {sourceTerrain},
+	"island":      {sourceTerrain},
+	"font":        {sourceText},
+	"textsize":    {sourceText},
+	"align":       {sourceText},
+	"linespacing": {sourceText},
+	"bevel":       {sourceText},
+	"round":       {sourceText},
+	"exaggerate":  {sourceDEM},
+	"elevation":   {sourceDEM},
+	"splat":       {sourcePointCloud},
+	"fill":        {sourcePointCloud},
+	"rotate":      cameraSources,
+	"zoom":        cameraSources,
+	"perspective": cameraSources,
+	"fov":         cameraSources,
+	"axis":        cameraSources,
+	"path":        cameraSources,
+	"frames":      animatedSources,
+	"fps":         animatedSources,
+	"filter":      resampleSources,
+	"fit":         resampleSources,
+}
+
+// checkFlags returns an error if any of the flags that were set don't
+// apply to the kind of source.
+func checkFlags(kind sourceKind) (err error) {
+	flag.Visit(func(f *flag.Flag) {
+		kinds, ok := sourceFlags[f.Name]
+		if (err == nil) && ok && !slices.Contains(kinds, kind) {
+			err = usagef("-%v doesn't apply to %v", f.Name, kind)
+		}
+	})
+	return err
+}
+
+// source returns the kind of source selected by the flags and src. At
+// most one of a src, -text, -shape, -terrain, and -expr can be given,
+// and if none of them are, the src is read from stdin.
+func (c *config) source(inFile string) (sourceKind, error) {
+	var n int
+	for _, set := range []bool{inFile != "", c.text != "", c.shape != "", c.terrain, c.expr != ""} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return 0, usagef("Only one of a src, -text, -shape, -terrain, and -expr can be specified")
+	}
+
+	switch {
+	case c.text != "":
+		return sourceText, nil
+	case c.shape != "":
+		return sourceShape, nil
+	case c.terrain:
+		return sourceTerrain, nil
+	case c.expr != "":
+		return sourceExpr, nil
+	case isDEM(inFile) || c.terrainRGB:
+		return sourceDEM, nil
+	case isScene(inFile):
+		return sourceScene, nil
+	case isMesh(inFile):
+		return sourceMesh, nil
+	case isPointCloud(inFile):
+		return sourcePointCloud, nil
+	default:
+		return sourceImage, nil
+	}
+}
+
+// size returns the size that depth maps that aren't resampled are
+// rendered at.
+func (c *config) size() image.Point {
+	size := image.Pt(c.width, c.height)
+	if size.X <= 0 {
+		size.X = 800
+	}
+	if size.Y <= 0 {
+		size.Y = 600
+	}
+	return size
+}
+
+// depthMaps loads or generates the depth map of the kind of source. If
+// an animation is being generated, anim holds its frames, and in is
+// the first of them.
+func (c *config) depthMaps(kind sourceKind, inFile string) (in sirdsc.DepthMap, anim []sirdsc.DepthMap, err error) {
+	switch kind {
+	case sourceShape:
+		in, err = makeShape(c.shape, c.size(), float64(c.maxDepth))
+		if err != nil {
+			return nil, nil, usagef("Invalid shape: %v", err)
+		}
+		return in, nil, nil
+	case sourceTerrain:
+		return sirdsc.NewTerrainDepthMap(c.size(), &sirdsc.TerrainOptions{
+			Seed:      c.seed,
+			Octaves:   c.octaves,
+			Roughness: c.roughness,
+			SeaLevel:  c.seaLevel,
+			Island:    c.island,
+			Depth:     float64(c.maxDepth),
+		}), nil, nil
+	case sourceExpr:
+		return c.exprDepthMaps()
+	case sourceText:
+		in, err = c.textDepthMap()
+	case sourceDEM:
+		in, err = c.demDepthMap(inFile)
+	case sourceMesh, sourcePointCloud, sourceScene:
+		return c.renderDepthMaps(kind, inFile)
+	default:
+		in, err = c.imageDepthMap(inFile)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if (c.width > 0) || (c.height > 0) {
+		in, err = c.resample(in)
+	}
+	return in, nil, err
+}
+
+// exprDepthMaps compiles -expr, and, if -frames is set, returns an
+// animation of it in which t counts the seconds since the first frame.
+func (c *config) exprDepthMaps() (in sirdsc.DepthMap, anim []sirdsc.DepthMap, err error) {
+	dm, err := sirdsc.NewExprDepthMap(c.expr, image.Rectangle{Max: c.size()})
+	if err != nil {
+		return nil, nil, usagef("Invalid expression %q: %v", c.expr, err)
+	}
+	dm.Seed = c.seed
+	if c.frames <= 0 {
+		return dm, nil, nil
+	}
+
+	// NewGIF uses 10 frames per second if fps isn't positive.
+	rate := c.fps
+	if rate <= 0 {
+		rate = 10
+	}
+
+	anim = make([]sirdsc.DepthMap, c.frames)
+	for i := range anim {
+		frame := *dm
+		frame.T = float64(i) / rate
+		anim[i] = &frame
+	}
+	return dm, anim, nil
+}
+
+// textDepthMap renders -text.
+func (c *config) textDepthMap() (sirdsc.DepthMap, error) {
+	var a sirdsc.TextAlign
+	switch c.align {
+	case "left":
+		a = sirdsc.AlignLeft
+	case "center":
+		a = sirdsc.AlignCenter
+	case "right":
+		a = sirdsc.AlignRight
+	default:
+		return nil, usagef("Unknown alignment: %q", c.align)
+	}
+
+	dm, err := makeText(c.text, c.fontFile, sirdsc.TextOptions{
+		Size:        c.textSize,
+		Align:       a,
+		LineSpacing: c.lineSpacing,
+		Depth:       float64(c.maxDepth),
+		Bevel:       c.bevel,
+		Round:       c.round,
+		Padding:     int(c.textSize / 2),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to render text: %w", err)
+	}
+	return dm, nil
+}
+
+// demDepthMap loads the elevation model in inFile.
+func (c *config) demDepthMap(inFile string) (sirdsc.DepthMap, error) {
+	low, high, ok, err := parseElevation(c.elevation)
+	if err != nil {
+		return nil, usagef("Invalid elevation range %q: %v", c.elevation, err)
+	}
+
+	g, err := loadDEM(inFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load elevation model %q: %w", inFile, err)
+	}
+
+	dm := dem.NewDepthMap(g, c.maxDepth)
+	if ok {
+		dm.Low, dm.High = low, high
+	}
+	dm.Exaggeration = c.exaggerate
+	return dm, nil
+}
+
+// renderDepthMaps loads the mesh, point cloud, or scene in inFile and
+// renders it, or, if -frames is set, an animation of it.
+func (c *config) renderDepthMaps(kind sourceKind, inFile string) (in sirdsc.DepthMap, anim []sirdsc.DepthMap, err error) {
+	// cam is the camera to view the src through, and render renders a
+	// single frame of it through a camera.
+	var cam mesh.Camera
+	var render func(d *sirdsc.Depth, cam *mesh.Camera)
+	switch kind {
+	case sourceScene:
+		scene, err := readScene(inFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to load scene %q: %w", inFile, err)
+		}
+		cam = scene.Camera
+		render = func(d *sirdsc.Depth, cam *mesh.Camera) {
+			sdf.Render(d, scene.Shape, cam, float64(c.maxDepth))
+		}
+	case sourceMesh:
+		m, err := readMesh(inFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to load mesh %q: %w", inFile, err)
+		}
+		render = func(d *sirdsc.Depth, cam *mesh.Camera) {
+			mesh.Render(d, m, cam, float64(c.maxDepth))
+		}
+	default:
+		pc, err := readPointCloud(inFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to load point cloud %q: %w", inFile, err)
+		}
+		opts := mesh.SplatOptions{Radius: c.splat, Fill: c.fill}
+		render = func(d *sirdsc.Depth, cam *mesh.Camera) {
+			mesh.RenderPoints(d, pc, cam, float64(c.maxDepth), &opts)
+		}
+	}
+
+	// The camera flags override the camera of a scene only if they are
+	// set. Their defaults are the same as the zero Camera's.
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["rotate"] {
+		rotation, err := parseRotation(c.rotate)
+		if err != nil {
+			return nil, nil, usagef("Invalid rotation %q: %v", c.rotate, err)
+		}
+		cam.Rotation = rotation
+	}
+	if set["zoom"] {
+		cam.Zoom = c.zoom
+	}
+	if set["perspective"] {
+		cam.Projection = mesh.Orthographic
+		if c.perspective {
+			cam.Projection = mesh.Perspective
+		}
+	}
+	if set["fov"] {
+		cam.FOV = c.fov * math.Pi / 180
+	}
+
+	cams := []mesh.Camera{cam}
+	if c.frames > 0 {
+		cams, err = animate(cam, c.frames, c.axis, c.path)
+		if err != nil {
+			return nil, nil, usagef("Invalid animation: %v", err)
+		}
+	}
+	dms := make([]sirdsc.DepthMap, len(cams))
+	for i := range cams {
+		d := sirdsc.NewDepth(image.Rectangle{Max: c.size()})
+		render(d, &cams[i])
+		dms[i] = d
+	}
+	if c.frames > 0 {
+		anim = dms
+	}
+	return dms[0], anim, nil
+}
+
+// imageDepthMap loads the image or PFM file in inFile, applying the
+// transfer flags to images.
+func (c *config) imageDepthMap(inFile string) (sirdsc.DepthMap, error) {
+	var ch sirdsc.Channel
+	switch c.channel {
+	case "max":
+		ch = sirdsc.ChannelMax
+	case "luma":
+		ch = sirdsc.ChannelLuma
+	case "red", "r":
+		ch = sirdsc.ChannelRed
+	case "green", "g":
+		ch = sirdsc.ChannelGreen
+	case "blue", "b":
+		ch = sirdsc.ChannelBlue
+	case "alpha", "a":
+		ch = sirdsc.ChannelAlpha
+	default:
+		return nil, usagef("Unknown channel: %q", c.channel)
+	}
+
+	points, err := sirdsc.ParseCurve(c.curve)
+	if err != nil {
+		return nil, usagef("Invalid curve %q: %v", c.curve, err)
+	}
+
+	in, err := loadDepthMap(inFile, sirdsc.ImageDepthMap{
+		Max:       c.maxDepth,
+		Flat:      c.flat,
+		Inverse:   c.inverse,
+		Signed:    c.signed,
+		Channel:   ch,
+		AlphaMask: c.alphaMask,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %q: %w", inFile, err)
+	}
+
+	dm, ok := in.(sirdsc.ImageDepthMap)
+	if !ok {
+		// PFM files hold depths, not pixels, so none of the flags that
+		// adjust the pixels of images apply to them.
+		return in, checkFlags(sourcePFM)
+	}
+
+	var transfers []sirdsc.Transfer
+	if c.autoLevels {
+		transfers = append(transfers, dm.AutoLevels())
+	}
+	if c.equalize {
+		transfers = append(transfers, dm.Equalize())
+	}
+	if len(points) > 0 {
+		transfers = append(transfers, sirdsc.Curve(points...))
+	}
+	if c.gamma != 1 {
+		transfers = append(transfers, sirdsc.Gamma(c.gamma))
+	}
+	if c.posterize >= 2 {
+		transfers = append(transfers, sirdsc.Posterize(c.posterize))
+	}
+	if len(transfers) > 0 {
+		dm.Transfer = sirdsc.Compose(transfers...)
+	}
+	return dm, nil
+}
+
+// resample resamples in to -width and -height.
+func (c *config) resample(in sirdsc.DepthMap) (sirdsc.DepthMap, error) {
+	var f sirdsc.Filter
+	switch c.filter {
+	case "nearest":
+		f = sirdsc.NearestNeighbor
+	case "bilinear":
+		f = sirdsc.Bilinear
+	case "bicubic":
+		f = sirdsc.Bicubic
+	default:
+		return nil, usagef("Unknown filter: %q", c.filter)
+	}
+
+	var mode sirdsc.ResampleMode
+	switch c.fit {
+	case "fit":
+		mode = sirdsc.Fit
+	case "fill":
+		mode = sirdsc.Fill
+	case "stretch":
+		mode = sirdsc.Stretch
+	default:
+		return nil, usagef("Unknown fit: %q", c.fit)
+	}
+
+	return sirdsc.Resample(in, image.Pt(c.width, c.height), f, mode), nil
+}
+
+// pattern returns the pattern to generate the stereogram with.
+func (c *config) pattern() (image.Image, error) {
+	if c.patFile != "" {
+		pat, err := loadImage(c.patFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %q: %w", c.patFile, err)
+		}
+		return pat, nil
+	}
+
+	if c.sym {
+		return &sirdsc.SymmetricRandImage{Seed: c.seed}, nil
+	}
+	return &sirdsc.RandImage{Seed: c.seed}, nil
+}
+
+// options returns the options to generate the stereogram with.
+func (c *config) options() (*sirdsc.Options, error) {
+	mode := sirdsc.WallEyed
+	if c.cross {
+		mode = sirdsc.CrossEyed
+	}
+
+	var geometry *sirdsc.ViewingGeometry
+	if c.dpi > 0 {
+		geometry = &sirdsc.ViewingGeometry{
+			DPI:           c.dpi,
+			EyeSeparation: c.eyeSep,
+			Distance:      c.distance,
+			FarPlane:      c.farPlane,
+			Mu:            c.mu,
+		}
+	}
+
+	algorithm := sirdsc.Copy
+	if c.hsr {
+		algorithm = sirdsc.HiddenSurface
+	}
+
+	propagation := sirdsc.PropagateRight
+	if c.center {
+		propagation = sirdsc.PropagateOut
+	}
+
+	opts := sirdsc.Options{
+		PartSize:    c.partSize,
+		Algorithm:   algorithm,
+		Mode:        mode,
+		Geometry:    geometry,
+		MaxDepth:    c.maxDepth,
+		Propagation: propagation,
+		Oversample:  c.oversample,
+	}
+	switch c.framing {
+	case "extra":
+		opts.Framing = sirdsc.ExtraStrip
+	case "crop":
+		opts.Framing = sirdsc.Cropped
+	case "center":
+		opts.Framing = sirdsc.Centered
+	default:
+		return nil, usagef("Unknown framing: %q", c.framing)
+	}
+	return &opts, nil
+}
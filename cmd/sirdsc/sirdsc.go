@@ -1,21 +1,32 @@
 package main
 
 import (
-	"flag"
+	"bufio"
+	"context"
 	"fmt"
 	"image"
-	_ "image/gif"
+	"image/gif"
 	_ "image/jpeg"
 	"image/png"
 	"io"
+	"math"
 	"os"
-	"time"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 
 	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/dem"
+	"github.com/DeedleFake/sirdsc/depth"
+	"github.com/DeedleFake/sirdsc/mesh"
+	"github.com/DeedleFake/sirdsc/netpbm"
+	"github.com/DeedleFake/sirdsc/sdf"
+	"golang.org/x/image/font/opentype"
 )
 
 func loadImage(file string) (image.Image, error) {
@@ -33,6 +44,328 @@ func loadImage(file string) (image.Image, error) {
 	return img, err
 }
 
+// loadDepthMap loads a depth map from file. The values in PFM files
+// are used directly as depths, so a depth map saved with -depthout can
+// be loaded again without any change. Any other kind of image is
+// wrapped in dm.
+func loadDepthMap(file string, dm sirdsc.ImageDepthMap) (sirdsc.DepthMap, error) {
+	f := io.Reader(os.Stdin)
+	if (file != "") && (file != "-") {
+		tmp, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer tmp.Close()
+		f = tmp
+	}
+
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(2)
+	switch string(magic) {
+	case "Pf", "PF":
+		return netpbm.DecodePFM(r)
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	dm.Image = img
+	return dm, nil
+}
+
+// isDEM returns true if file is an elevation model that can be loaded
+// by loadDEM, based on its extension. Terrain-RGB tiles from Mapbox
+// have the extension .pngraw.
+func isDEM(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".asc", ".pngraw":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadDEM loads the elevations in file. ESRI ASCII grid files are
+// recognized by their extension, and anything else is read as a
+// Terrain-RGB image.
+func loadDEM(file string) (*dem.Grid, error) {
+	f := io.Reader(os.Stdin)
+	if (file != "") && (file != "-") {
+		tmp, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer tmp.Close()
+		f = tmp
+	}
+
+	r := bufio.NewReader(f)
+	if strings.EqualFold(filepath.Ext(file), ".asc") {
+		return dem.ReadASCIIGrid(r)
+	}
+	return dem.DecodeTerrainRGB(r)
+}
+
+// parseElevation parses a range of elevations in the form "low,high".
+// If str is empty, ok is false.
+func parseElevation(str string) (low, high float64, ok bool, err error) {
+	if str == "" {
+		return 0, 0, false, nil
+	}
+
+	lstr, hstr, found := strings.Cut(str, ",")
+	if !found {
+		return 0, 0, false, fmt.Errorf("no comma")
+	}
+	low, err = strconv.ParseFloat(strings.TrimSpace(lstr), 64)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	high, err = strconv.ParseFloat(strings.TrimSpace(hstr), 64)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if high <= low {
+		return 0, 0, false, fmt.Errorf("high elevation isn't higher than low one")
+	}
+	return low, high, true, nil
+}
+
+// isScene returns true if file is a signed distance function scene
+// that can be loaded by readScene, based on its extension.
+func isScene(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".sdf", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+// readScene reads the scene in file.
+func readScene(file string) (*sdf.Scene, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return sdf.ReadScene(bufio.NewReader(f))
+}
+
+// isMesh returns true if file is a mesh that can be loaded by
+// readMesh, based on its extension.
+func isMesh(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".obj", ".stl":
+		return true
+	default:
+		return false
+	}
+}
+
+// readMesh reads the mesh in file.
+func readMesh(file string) (*mesh.Mesh, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	read := mesh.ReadOBJ
+	if strings.EqualFold(filepath.Ext(file), ".stl") {
+		read = mesh.ReadSTL
+	}
+	return read(bufio.NewReader(f))
+}
+
+// isPointCloud returns true if file is a point cloud that can be
+// loaded by readPointCloud, based on its extension.
+func isPointCloud(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".ply", ".xyz":
+		return true
+	default:
+		return false
+	}
+}
+
+// readPointCloud reads the point cloud in file.
+func readPointCloud(file string) (*mesh.PointCloud, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	read := mesh.ReadXYZ
+	if strings.EqualFold(filepath.Ext(file), ".ply") {
+		read = mesh.ReadPLY
+	}
+	return read(bufio.NewReader(f))
+}
+
+// parseVec parses a vector in the form "x,y,z". Missing components are
+// zero.
+func parseVec(str string) (mesh.Vec, error) {
+	var c [3]float64
+	if str == "" {
+		return mesh.Vec{}, nil
+	}
+
+	parts := strings.Split(str, ",")
+	if len(parts) > len(c) {
+		return mesh.Vec{}, fmt.Errorf("too many components")
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return mesh.Vec{}, err
+		}
+		c[i] = v
+	}
+	return mesh.Vec{X: c[0], Y: c[1], Z: c[2]}, nil
+}
+
+// parseRotation parses a rotation in the form "x,y,z", where each of
+// the components is an angle in degrees. Missing components are zero.
+func parseRotation(str string) (mesh.Vec, error) {
+	v, err := parseVec(str)
+	return v.Mul(math.Pi / 180), err
+}
+
+// animate returns the cameras for an animation of frames frames based
+// on cam. If path is empty, the animation is a turntable around axis,
+// which is parsed by parseVec. Otherwise, it follows the path, which is
+// parsed by parsePath.
+func animate(cam mesh.Camera, frames int, axis, path string) ([]mesh.Camera, error) {
+	if path != "" {
+		keys, err := parsePath(path, cam)
+		if err != nil {
+			return nil, fmt.Errorf("path: %w", err)
+		}
+		return mesh.Path(keys, frames), nil
+	}
+
+	var err error
+	cam.Axis, err = parseVec(axis)
+	if err != nil {
+		return nil, fmt.Errorf("axis: %w", err)
+	}
+	return mesh.Turntable(cam, frames), nil
+}
+
+// parsePath parses a camera path in the form "x,y,z@zoom;x,y,z@zoom;...",
+// where each x,y,z is a rotation in the form accepted by parseRotation
+// and each @zoom is optional. The keyframes are spaced evenly in time,
+// and are based on cam.
+func parsePath(str string, cam mesh.Camera) ([]mesh.Keyframe, error) {
+	var keys []mesh.Keyframe
+	for i, key := range strings.Split(str, ";") {
+		rot, zoom, ok := strings.Cut(key, "@")
+
+		var err error
+		cam.Rotation, err = parseRotation(rot)
+		if err != nil {
+			return nil, fmt.Errorf("keyframe %v: %w", i+1, err)
+		}
+		if ok {
+			cam.Zoom, err = strconv.ParseFloat(strings.TrimSpace(zoom), 64)
+			if err != nil {
+				return nil, fmt.Errorf("keyframe %v: %w", i+1, err)
+			}
+		}
+
+		keys = append(keys, mesh.Keyframe{Time: float64(i), Camera: cam})
+	}
+	return keys, nil
+}
+
+// saveGIF writes g to file, or to stdout if file is empty or "-".
+func saveGIF(file string, g *gif.GIF) error {
+	f := io.Writer(os.Stdout)
+	if (file != "") && (file != "-") {
+		tmp, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer tmp.Close()
+		f = tmp
+	}
+
+	return gif.EncodeAll(f, g)
+}
+
+// makeShape returns a depth map of size containing the shape called
+// name, centered and scaled to fit, with its closest point at height.
+func makeShape(name string, size image.Point, height float64) (sirdsc.DepthMap, error) {
+	bounds := image.Rectangle{Max: size}
+	c := size.Div(2)
+	r := float64(min(size.X, size.Y)) * 0.4
+
+	var shape sirdsc.DepthMap
+	switch name {
+	case "sphere":
+		shape = depth.Sphere{Center: c, Radius: r, Height: height}
+	case "cone":
+		shape = depth.Cone{Center: c, Radius: r, Height: height}
+	case "cylinder":
+		shape = depth.Cylinder{Center: c, Radius: r / 2, Length: float64(size.X) * 0.8, Height: height}
+	case "torus":
+		shape = depth.Torus{Center: c, Radius: r * 0.7, Thickness: r * 0.3, Height: height}
+	case "box":
+		shape = depth.Box{Rect: bounds.Inset(int(float64(min(size.X, size.Y)) * 0.15)), Height: height, Bevel: r / 4}
+	case "plane":
+		shape = depth.Plane{Rect: bounds, Depth: height / 2, SlopeX: height / float64(2*size.X), SlopeY: height / float64(2*size.Y)}
+	case "ramp":
+		shape = depth.Ramp{Rect: bounds, To: height}
+	case "waves":
+		shape = depth.Waves{Rect: bounds, Wavelength: r / 2, Angle: math.Pi / 4, Height: height}
+	case "ripples":
+		shape = depth.Ripples{Center: c, Radius: r * 1.25, Wavelength: r / 4, Height: height}
+	case "annulus":
+		shape = depth.Annulus{Center: c, Inner: r / 2, Outer: r, Height: height}
+	default:
+		return nil, fmt.Errorf("unknown shape %q", name)
+	}
+
+	// Give the shape the same bounds as the rest of the image, as
+	// otherwise the stereogram would be cropped to the shape.
+	return sirdsc.Max(sirdsc.NewDepth(bounds), shape), nil
+}
+
+// makeText renders text into a depth map with the font in the file
+// fontFile, or with the default font if fontFile is empty.
+func makeText(text, fontFile string, opts sirdsc.TextOptions) (sirdsc.DepthMap, error) {
+	if fontFile != "" {
+		data, err := os.ReadFile(fontFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Font, err = opentype.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %w", fontFile, err)
+		}
+	}
+
+	return sirdsc.NewTextDepthMap(text, &opts)
+}
+
+func saveDepthMap(file string, dm sirdsc.DepthMap) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = netpbm.EncodePFM(f, dm)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
 // TODO: Encode different types based on the extension.
 func saveImage(file string, img image.Image) error {
 	f := io.Writer(os.Stdout)
@@ -49,68 +382,69 @@ func saveImage(file string, img image.Image) error {
 }
 
 func main() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %v [options] [src]\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-	}
-	partSize := flag.Int("partsize", 100, "Size of sections in the SIRDS")
-	maxDepth := flag.Int("depth", sirdsc.DefaultMaxImageDepth, "Maximum depth")
-	flat := flag.Bool("flat", false, "Generate an image with only two planes")
-	inverse := flag.Bool("inverse", false, "Treat darker pixels as closer in the depth map")
-	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "Color generation seed")
-	sym := flag.Bool("sym", false, "Use symmetric generation")
-	patFile := flag.String("pat", "", "If not empty, use the specified file as the pattern instead of randomizing")
-	outFile := flag.String("o", "", "Output file")
-	flag.Parse()
-
-	var inFile string
-	switch flag.NArg() {
-	case 0:
-	case 1:
-		inFile = flag.Arg(0)
-	default:
-		flag.Usage()
-		os.Exit(2)
+	c, inFile := parseFlags()
+
+	kind, err := c.source(inFile)
+	if err != nil {
+		exit(err)
+	}
+	err = checkFlags(kind)
+	if err != nil {
+		exit(err)
+	}
+	if (c.frames > 0) && (c.depthFile != "") {
+		exit(usagef("The depth map of an animation can't be saved"))
 	}
 
-	inImg, err := loadImage(inFile)
+	// anim holds the frames of an animation, if one is being generated,
+	// in which case in is its first frame.
+	in, anim, err := c.depthMaps(kind, inFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open %q: %v\n", inFile, err)
-		os.Exit(1)
+		exit(err)
 	}
-	in := sirdsc.ImageDepthMap{
-		Image: inImg,
 
-		Max:     *maxDepth,
-		Flat:    *flat,
-		Inverse: *inverse,
+	pat, err := c.pattern()
+	if err != nil {
+		exit(err)
 	}
 
-	pat := image.Image(&sirdsc.RandImage{Seed: *seed})
-	if *sym {
-		pat = &sirdsc.SymmetricRandImage{Seed: *seed}
+	opts, err := c.options()
+	if err != nil {
+		exit(err)
 	}
-	if *patFile != "" {
-		pat, err = loadImage(*patFile)
+
+	if c.depthFile != "" {
+		err := saveDepthMap(c.depthFile, in)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to open %q: %v\n", *patFile, err)
-			os.Exit(1)
+			exit(fmt.Errorf("Failed to write depth map to %q: %w", c.depthFile, err))
 		}
 	}
 
-	inb := in.Bounds()
-	out := image.NewNRGBA(image.Rect(
-		inb.Min.X,
-		inb.Min.Y,
-		inb.Max.X+*partSize,
-		inb.Max.Y,
-	))
-	sirdsc.Generate(out, in, pat, *partSize)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if anim != nil {
+		imgs, err := sirdsc.GenerateFrames(ctx, anim, pat, opts)
+		if err != nil {
+			exit(fmt.Errorf("Failed to generate SIRDS: %w", err))
+		}
+
+		err = saveGIF(c.outFile, sirdsc.NewGIF(imgs, c.fps))
+		if err != nil {
+			exit(fmt.Errorf("Failed to write to %q: %w", c.outFile, err))
+		}
+		return
+	}
+
+	out := image.NewNRGBA(opts.OutputBounds(in.Bounds(), pat))
+
+	err = sirdsc.GenerateContext(ctx, out, in, pat, opts)
+	if err != nil {
+		exit(fmt.Errorf("Failed to generate SIRDS: %w", err))
+	}
 
-	err = saveImage(*outFile, out)
+	err = saveImage(c.outFile, out)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write to %q: %v", *outFile, err)
-		os.Exit(1)
+		exit(fmt.Errorf("Failed to write to %q: %w", c.outFile, err))
 	}
 }
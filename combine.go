@@ -0,0 +1,238 @@
+package sirdsc
+
+import (
+	"image"
+	"math"
+)
+
+// funcDepthMap is a DepthMapF whose depths are calculated by a
+// function. It is used to implement the depth map combinators.
+type funcDepthMap struct {
+	bounds image.Rectangle
+	at     func(x, y int) float64
+}
+
+func (dm funcDepthMap) Bounds() image.Rectangle { // nolint
+	return dm.bounds
+}
+
+func (dm funcDepthMap) At(x, y int) int { // nolint
+	return int(dm.at(x, y))
+}
+
+func (dm funcDepthMap) AtF(x, y int) float64 { // nolint
+	return dm.at(x, y)
+}
+
+// atF returns the depth of dm at (x, y), using its fractional depth if
+// it is a DepthMapF.
+func atF(dm DepthMap, x, y int) float64 {
+	if dm, ok := dm.(DepthMapF); ok {
+		return dm.AtF(x, y)
+	}
+	return float64(dm.At(x, y))
+}
+
+// unionBounds returns the smallest rectangle that contains the bounds
+// of all of dms.
+func unionBounds(dms []DepthMap) image.Rectangle {
+	var r image.Rectangle
+	for _, dm := range dms {
+		r = r.Union(dm.Bounds())
+	}
+	return r
+}
+
+// reduce returns a depth map that combines the depths of dms with f.
+// Its bounds are the union of their bounds.
+func reduce(dms []DepthMap, f func(d1, d2 float64) float64) DepthMapF {
+	return funcDepthMap{
+		bounds: unionBounds(dms),
+		at: func(x, y int) float64 {
+			if len(dms) == 0 {
+				return 0
+			}
+
+			d := atF(dms[0], x, y)
+			for _, dm := range dms[1:] {
+				d = f(d, atF(dm, x, y))
+			}
+			return d
+		},
+	}
+}
+
+// Max returns a depth map whose depth at every point is the largest of
+// the depths of dms, placing the closest surface of each of them in
+// front. Its bounds are the union of their bounds.
+func Max(dms ...DepthMap) DepthMapF {
+	return reduce(dms, func(d1, d2 float64) float64 { return max(d1, d2) })
+}
+
+// Min returns a depth map whose depth at every point is the smallest
+// of the depths of dms. Its bounds are the union of their bounds.
+func Min(dms ...DepthMap) DepthMapF {
+	return reduce(dms, func(d1, d2 float64) float64 { return min(d1, d2) })
+}
+
+// Add returns a depth map whose depth at every point is the sum of the
+// depths of dms. This can be used to place details on top of a larger
+// shape. Its bounds are the union of their bounds.
+func Add(dms ...DepthMap) DepthMapF {
+	return reduce(dms, func(d1, d2 float64) float64 { return d1 + d2 })
+}
+
+// Offset returns a depth map that moves every depth of dm closer by d.
+// It has the same bounds as dm.
+func Offset(dm DepthMap, d float64) DepthMapF {
+	return funcDepthMap{
+		bounds: dm.Bounds(),
+		at:     func(x, y int) float64 { return atF(dm, x, y) + d },
+	}
+}
+
+// Scale returns a depth map that multiplies every depth of dm by s. It
+// has the same bounds as dm.
+func Scale(dm DepthMap, s float64) DepthMapF {
+	return funcDepthMap{
+		bounds: dm.Bounds(),
+		at:     func(x, y int) float64 { return atF(dm, x, y) * s },
+	}
+}
+
+// Clamp returns a depth map that limits every depth of dm to the range
+// [lo, hi]. It has the same bounds as dm.
+func Clamp(dm DepthMap, lo, hi float64) DepthMapF {
+	return funcDepthMap{
+		bounds: dm.Bounds(),
+		at:     func(x, y int) float64 { return min(max(atF(dm, x, y), lo), hi) },
+	}
+}
+
+// Invert returns a depth map that turns dm inside out, mapping a depth
+// of d to max-d. The depth map must be in the range [0, max] for the
+// result to be as well. It has the same bounds as dm.
+func Invert(dm DepthMap, max float64) DepthMapF {
+	return funcDepthMap{
+		bounds: dm.Bounds(),
+		at:     func(x, y int) float64 { return max - atF(dm, x, y) },
+	}
+}
+
+// Translate returns a depth map that moves dm by p. Its bounds are
+// dm's bounds moved by p.
+func Translate(dm DepthMap, p image.Point) DepthMapF {
+	return funcDepthMap{
+		bounds: dm.Bounds().Add(p),
+		at:     func(x, y int) float64 { return atF(dm, x-p.X, y-p.Y) },
+	}
+}
+
+// Masked returns a depth map that multiplies every depth of dm by the
+// alpha of mask at the same point, in the same way that mask is used
+// by draw.DrawMask. Where mask is transparent, or outside of its
+// bounds, the depth is zero. Its bounds are the intersection of dm's
+// bounds and mask's bounds. As with draw.DrawMask, a nil mask is fully
+// opaque, so the depths are dm's.
+func Masked(dm DepthMap, mask image.Image) DepthMapF {
+	bounds := dm.Bounds()
+	if mask != nil {
+		bounds = bounds.Intersect(mask.Bounds())
+	}
+	return funcDepthMap{
+		bounds: bounds,
+		at: func(x, y int) float64 {
+			a := maskAlpha(mask, x, y)
+			if a == 0 {
+				return 0
+			}
+			return atF(dm, x, y) * a
+		},
+	}
+}
+
+// AlphaBlend returns a depth map that blends from b to a using the
+// alpha of mask. Where mask is opaque, the depth is a's, and where it
+// is transparent, or outside of its bounds, the depth is b's. Its
+// bounds are the union of a's bounds and b's bounds. A nil mask is
+// fully opaque.
+func AlphaBlend(a, b DepthMap, mask image.Image) DepthMapF {
+	return funcDepthMap{
+		bounds: a.Bounds().Union(b.Bounds()),
+		at: func(x, y int) float64 {
+			alpha := maskAlpha(mask, x, y)
+			switch alpha {
+			case 0:
+				return atF(b, x, y)
+			case 1:
+				return atF(a, x, y)
+			}
+			return atF(a, x, y)*alpha + atF(b, x, y)*(1-alpha)
+		},
+	}
+}
+
+// maskAlpha returns the alpha of mask at (x, y) in the range [0, 1].
+// A nil mask is opaque everywhere.
+func maskAlpha(mask image.Image, x, y int) float64 {
+	if mask == nil {
+		return 1
+	}
+	if !(image.Point{x, y}.In(mask.Bounds())) {
+		return 0
+	}
+	_, _, _, a := mask.At(x, y).RGBA()
+	return float64(a) / math.MaxUint16
+}
+
+// A TiledDepth extends another depth map by tiling it to fill Rect, in
+// the same way that TiledImage extends an image. If the underlying
+// depth map's bounds are empty, there is nothing to tile, and every
+// depth is zero.
+type TiledDepth struct {
+	DepthMap
+
+	// Rect is the bounds of the tiled depth map. Depths outside of it
+	// are zero. If it is empty, the depth map is tiled infinitely in
+	// every direction, and its bounds are the same as TiledImage's.
+	Rect image.Rectangle
+}
+
+// c returns the point in the underlying depth map that (x, y) is
+// tiled from, or false if there isn't one.
+func (dm TiledDepth) c(x, y int) (int, int, bool) {
+	if !dm.Rect.Empty() && !(image.Point{x, y}.In(dm.Rect)) {
+		return 0, 0, false
+	}
+	b := dm.DepthMap.Bounds()
+	if b.Empty() {
+		return 0, 0, false
+	}
+	x, y = TiledImage{Image: b}.c(x, y)
+	return x, y, true
+}
+
+func (dm TiledDepth) Bounds() image.Rectangle { // nolint
+	if dm.Rect.Empty() {
+		return TiledImage{}.Bounds()
+	}
+	return dm.Rect
+}
+
+func (dm TiledDepth) At(x, y int) int { // nolint
+	x, y, ok := dm.c(x, y)
+	if !ok {
+		return 0
+	}
+	return dm.DepthMap.At(x, y)
+}
+
+// AtF returns the fractional depth at (x, y) if the underlying depth
+// map is a DepthMapF.
+func (dm TiledDepth) AtF(x, y int) float64 {
+	x, y, ok := dm.c(x, y)
+	if !ok {
+		return 0
+	}
+	return atF(dm.DepthMap, x, y)
+}
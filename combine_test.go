@@ -0,0 +1,136 @@
+package sirdsc_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestCombinators(t *testing.T) {
+	a := constDepthMap{rect: image.Rect(0, 0, 10, 10), depth: 4}
+	b := constDepthMapF{rect: image.Rect(5, 5, 20, 20), depth: 2.5}
+
+	mask := image.NewAlpha(image.Rect(0, 0, 8, 8))
+	mask.SetAlpha(6, 6, color.Alpha{0xff})
+	mask.SetAlpha(7, 7, color.Alpha{0x33})
+
+	type point struct {
+		x, y int
+		want float64
+	}
+	tests := []struct {
+		name   string
+		dm     sirdsc.DepthMapF
+		bounds image.Rectangle
+		points []point
+	}{
+		{
+			name:   "Max",
+			dm:     sirdsc.Max(a, b),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 4}, {6, 6, 4}, {15, 15, 2.5}, {30, 30, 0}},
+		},
+		{
+			name:   "Min",
+			dm:     sirdsc.Min(a, b),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 0}, {6, 6, 2.5}},
+		},
+		{
+			name:   "Add",
+			dm:     sirdsc.Add(a, b, a),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 8}, {6, 6, 10.5}, {15, 15, 2.5}},
+		},
+		{
+			name:   "Offset",
+			dm:     sirdsc.Offset(b, -1),
+			bounds: b.rect,
+			points: []point{{6, 6, 1.5}},
+		},
+		{
+			name:   "Scale",
+			dm:     sirdsc.Scale(b, 2),
+			bounds: b.rect,
+			points: []point{{6, 6, 5}},
+		},
+		{
+			name:   "Clamp",
+			dm:     sirdsc.Clamp(sirdsc.Add(a, b), 1, 5),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 4}, {6, 6, 5}, {30, 30, 1}},
+		},
+		{
+			name:   "Invert",
+			dm:     sirdsc.Invert(a, 10),
+			bounds: a.rect,
+			points: []point{{0, 0, 6}},
+		},
+		{
+			name:   "Translate",
+			dm:     sirdsc.Translate(a, image.Pt(-5, 3)),
+			bounds: image.Rect(-5, 3, 5, 13),
+			points: []point{{-5, 3, 4}, {0, 0, 0}, {4, 12, 4}},
+		},
+		{
+			name:   "Masked",
+			dm:     sirdsc.Masked(a, mask),
+			bounds: image.Rect(0, 0, 8, 8),
+			points: []point{{0, 0, 0}, {6, 6, 4}, {7, 7, 0.8}, {9, 9, 0}},
+		},
+		{
+			name:   "AlphaBlend",
+			dm:     sirdsc.AlphaBlend(a, b, mask),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 0}, {6, 6, 4}, {7, 7, 0.2*4 + 0.8*2.5}, {9, 9, 2.5}},
+		},
+		{
+			name:   "MaskedNil",
+			dm:     sirdsc.Masked(a, nil),
+			bounds: image.Rect(0, 0, 10, 10),
+			points: []point{{0, 0, 4}, {9, 9, 4}, {10, 10, 0}},
+		},
+		{
+			name:   "AlphaBlendNil",
+			dm:     sirdsc.AlphaBlend(a, b, nil),
+			bounds: image.Rect(0, 0, 20, 20),
+			points: []point{{0, 0, 4}, {9, 9, 4}, {15, 15, 0}},
+		},
+		{
+			name:   "TiledDepth",
+			dm:     sirdsc.TiledDepth{DepthMap: sirdsc.Translate(a, image.Pt(2, 0)), Rect: image.Rect(0, 0, 100, 50)},
+			bounds: image.Rect(0, 0, 100, 50),
+			points: []point{{0, 0, 4}, {12, 0, 4}, {99, 49, 4}, {-3, -7, 0}, {100, 0, 0}},
+		},
+		{
+			name:   "TiledDepthInfinite",
+			dm:     sirdsc.TiledDepth{DepthMap: sirdsc.Translate(a, image.Pt(2, 0))},
+			bounds: sirdsc.TiledImage{}.Bounds(),
+			points: []point{{0, 0, 4}, {-3, -7, 4}, {1000, -1000, 4}},
+		},
+		{
+			name:   "TiledDepthEmpty",
+			dm:     sirdsc.TiledDepth{DepthMap: constDepthMap{}, Rect: image.Rect(0, 0, 100, 50)},
+			bounds: image.Rect(0, 0, 100, 50),
+			points: []point{{0, 0, 0}, {12, 3, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if b := test.dm.Bounds(); b != test.bounds {
+				t.Errorf("Bounds() == %v, want %v", b, test.bounds)
+			}
+			for _, p := range test.points {
+				if got := test.dm.AtF(p.x, p.y); got-p.want > 1e-9 || p.want-got > 1e-9 {
+					t.Errorf("AtF(%v, %v) == %v, want %v", p.x, p.y, got, p.want)
+				}
+				if got := test.dm.At(p.x, p.y); got != int(test.dm.AtF(p.x, p.y)) {
+					t.Errorf("At(%v, %v) == %v, inconsistent with AtF", p.x, p.y, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package dem
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ascHeaderKeys are the keys that can be in the header of an ESRI
+// ASCII grid file, in lowercase.
+var ascHeaderKeys = map[string]bool{
+	"ncols":        true,
+	"nrows":        true,
+	"xllcorner":    true,
+	"yllcorner":    true,
+	"xllcenter":    true,
+	"yllcenter":    true,
+	"cellsize":     true,
+	"dx":           true,
+	"dy":           true,
+	"nodata_value": true,
+}
+
+// maxASCIIGridCells is the largest number of cells that an ASCII grid
+// file can declare. Larger grids are assumed to be corrupt.
+const maxASCIIGridCells = 1 << 28
+
+// ReadASCIIGrid reads an ESRI ASCII grid file, which usually has the
+// extension .asc. The first row of the file is the top row of the
+// grid, and cells with the file's NODATA_value have no data. The
+// grid's CellSize is set from the file's cellsize, or from its dx and
+// dy if they are the same.
+func ReadASCIIGrid(r io.Reader) (*Grid, error) {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+
+	// The header is a list of keys and values, and the first word that
+	// isn't a known key is the first elevation.
+	header := make(map[string]float64)
+	var word string
+	for {
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: no elevations", ErrFormat)
+		}
+		word = s.Text()
+		key := strings.ToLower(word)
+		if !ascHeaderKeys[key] {
+			break
+		}
+		if _, ok := header[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate %v", ErrFormat, word)
+		}
+
+		if !s.Scan() {
+			return nil, fmt.Errorf("%w: no value for %v", ErrFormat, word)
+		}
+		v, err := strconv.ParseFloat(s.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %w", ErrFormat, word, err)
+		}
+		header[key] = v
+	}
+
+	w, h := header["ncols"], header["nrows"]
+	if (w < 1) || (h < 1) || (w != math.Trunc(w)) || (h != math.Trunc(h)) {
+		return nil, fmt.Errorf("%w: invalid size %vx%v", ErrFormat, w, h)
+	}
+	if (w > maxASCIIGridCells) || (h > maxASCIIGridCells/w) {
+		return nil, fmt.Errorf("%w: size %vx%v too large", ErrFormat, w, h)
+	}
+	n := int(w) * int(h)
+	nodata, hasNodata := header["nodata_value"]
+
+	// The elevations are appended as they're read rather than filled
+	// into a grid of the declared size, so that a short file can't
+	// allocate a huge one.
+	pix := make([]float32, 0, min(n, 1<<16))
+	for i := range n {
+		if i > 0 {
+			if !s.Scan() {
+				if err := s.Err(); err != nil {
+					return nil, err
+				}
+				return nil, fmt.Errorf("%w: %v elevations, want %v", ErrFormat, i, n)
+			}
+			word = s.Text()
+		}
+
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: elevation %v: %w", ErrFormat, i, err)
+		}
+		if hasNodata && (v == nodata) {
+			v = math.NaN()
+		}
+		pix = append(pix, float32(v))
+	}
+
+	g := Grid{
+		Pix:      pix,
+		Stride:   int(w),
+		Rect:     image.Rect(0, 0, int(w), int(h)),
+		CellSize: header["cellsize"],
+	}
+	if dx := header["dx"]; (g.CellSize == 0) && (dx == header["dy"]) {
+		g.CellSize = dx
+	}
+	return &g, nil
+}
@@ -0,0 +1,161 @@
+// Package dem reads digital elevation models, such as those used to
+// make maps of real landscapes, and turns them into depth maps. It
+// supports ESRI ASCII grid files and Mapbox-style Terrain-RGB images,
+// which pack elevations into the color channels of each pixel.
+//
+// Elevations are read into a Grid, which a DepthMap turns into depths
+// in the same way that sirdsc.ImageDepthMap does for the pixels of a
+// grayscale image.
+package dem
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+// ErrFormat is returned when the input is not a valid file of the
+// expected format.
+var ErrFormat = errors.New("dem: invalid format")
+
+// Grid is a grid of elevations, analogous to sirdsc.Depth. Each cell
+// of the grid is a pixel of the depth map that it becomes. Cells that
+// have no data, such as those outside of the area that was surveyed,
+// are NaN.
+type Grid struct {
+	// Pix holds the elevations. The elevation at (x, y) starts at
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []float32
+
+	// Stride is the Pix stride between vertically adjacent cells.
+	Stride int
+
+	// Rect is the grid's bounds.
+	Rect image.Rectangle
+
+	// CellSize is the width and height of each cell in the units of the
+	// file that the grid was read from, or zero if it isn't known.
+	CellSize float64
+}
+
+// NewGrid returns a new Grid with the given bounds. All of its cells
+// have no data.
+func NewGrid(r image.Rectangle) *Grid {
+	g := Grid{
+		Pix:    make([]float32, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+	nan := float32(math.NaN())
+	for i := range g.Pix {
+		g.Pix[i] = nan
+	}
+	return &g
+}
+
+// Bounds returns the bounds of the grid.
+func (g *Grid) Bounds() image.Rectangle {
+	return g.Rect
+}
+
+// Elevation returns the elevation at (x, y). Points outside of the
+// bounds and cells with no data are NaN.
+func (g *Grid) Elevation(x, y int) float64 {
+	if !image.Pt(x, y).In(g.Rect) {
+		return math.NaN()
+	}
+	return float64(g.Pix[g.PixOffset(x, y)])
+}
+
+// PixOffset returns the index of the element of Pix that corresponds
+// to the cell at (x, y).
+func (g *Grid) PixOffset(x, y int) int {
+	return (y-g.Rect.Min.Y)*g.Stride + (x - g.Rect.Min.X)
+}
+
+// Range returns the lowest and highest elevations in the grid,
+// ignoring cells with no data. If no cell has any data, both are zero.
+func (g *Grid) Range() (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for y := g.Rect.Min.Y; y < g.Rect.Max.Y; y++ {
+		i := g.PixOffset(g.Rect.Min.X, y)
+		for _, v := range g.Pix[i : i+g.Rect.Dx()] {
+			if v == v {
+				lo, hi = min(lo, float64(v)), max(hi, float64(v))
+			}
+		}
+	}
+	if lo > hi {
+		return 0, 0
+	}
+	return lo, hi
+}
+
+// DepthMap is a wrapper around a Grid that allows it to be used as a
+// sirdsc.DepthMap. Higher elevations are closer, and elevations are
+// mapped linearly to depths, with Low at a depth of zero. Cells with
+// no data are at a depth of zero, too.
+type DepthMap struct {
+	// The Grid to read elevations from.
+	Grid *Grid
+
+	// Low and High are the range of elevations that is mapped to
+	// depths. Lower elevations are clamped to Low and higher ones to
+	// High, which makes it possible to cut off the sea floor or to
+	// flatten peaks that would otherwise dwarf the rest of the terrain.
+	// If High isn't greater than Low, every depth is zero.
+	Low, High float64
+
+	// Max is the depth of an elevation of High when Exaggeration is 1.
+	//
+	// If Max is zero, sirdsc.DefaultMaxImageDepth is used instead.
+	Max int
+
+	// Exaggeration is the vertical exaggeration of the terrain. The
+	// depth of each elevation above Low is multiplied by it, so values
+	// greater than 1 make the relief of flat terrain easier to see. If
+	// it is zero, 1 is used instead.
+	Exaggeration float64
+}
+
+// NewDepthMap returns a DepthMap of g with Low and High set to the
+// range of its elevations.
+func NewDepthMap(g *Grid, max int) DepthMap {
+	lo, hi := g.Range()
+	return DepthMap{
+		Grid: g,
+		Low:  lo,
+		High: hi,
+		Max:  max,
+	}
+}
+
+// Bounds returns the same bounds as the underlying grid.
+func (dm DepthMap) Bounds() image.Rectangle {
+	return dm.Grid.Bounds()
+}
+
+func (dm DepthMap) At(x, y int) int { // nolint
+	return int(dm.AtF(x, y))
+}
+
+// AtF returns the depth at (x, y) without truncating it to an integer.
+func (dm DepthMap) AtF(x, y int) float64 {
+	e := dm.Grid.Elevation(x, y)
+	if math.IsNaN(e) || (dm.High <= dm.Low) {
+		return 0
+	}
+
+	depth := float64(dm.Max)
+	if depth == 0 {
+		depth = sirdsc.DefaultMaxImageDepth
+	}
+	if dm.Exaggeration != 0 {
+		depth *= dm.Exaggeration
+	}
+
+	e = min(max(e, dm.Low), dm.High)
+	return (e - dm.Low) / (dm.High - dm.Low) * depth
+}
@@ -0,0 +1,121 @@
+package dem_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc/dem"
+)
+
+func TestReadASCIIGrid(t *testing.T) {
+	const asc = `ncols        3
+nrows        2
+XLLCORNER    100.5
+yllcorner    -20
+cellsize     30
+NODATA_value -9999
+10 20.5 -9999
+-5
+0 1e3
+`
+
+	g, err := dem.ReadASCIIGrid(strings.NewReader(asc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (g.Rect != image.Rect(0, 0, 3, 2)) || (g.CellSize != 30) {
+		t.Fatalf("bounds %v, cell size %v", g.Rect, g.CellSize)
+	}
+
+	want := []float64{10, 20.5, math.NaN(), -5, 0, 1000}
+	for i, want := range want {
+		x, y := i%3, i/3
+		got := g.Elevation(x, y)
+		if (got != want) && !(math.IsNaN(got) && math.IsNaN(want)) {
+			t.Errorf("(%v, %v): %v, want %v", x, y, got, want)
+		}
+	}
+	if lo, hi := g.Range(); (lo != -5) || (hi != 1000) {
+		t.Errorf("range [%v, %v]", lo, hi)
+	}
+
+	for _, bad := range []string{
+		"ncols 2\nnrows 2\n1 2 3\n",
+		"ncols 2\nnrows 0\n",
+		"ncols 1\nncols 1\nnrows 1\n0\n",
+		"ncols 1\nnrows 1\nx\n",
+		"ncols 3000000000\nnrows 3000000000\n0\n",
+		"ncols 1e300\nnrows 1\n0\n",
+		"ncols 100000\nnrows 100000\n0\n",
+		"ncols 10000\nnrows 10000\n0\n",
+	} {
+		_, err := dem.ReadASCIIGrid(strings.NewReader(bad))
+		if err == nil {
+			t.Errorf("read %q without an error", bad)
+		}
+	}
+}
+
+func TestTerrainRGB(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0x01, 0x86, 0xa0, 0xff}) // 0 m.
+	img.SetNRGBA(1, 0, color.NRGBA{0x02, 0x0f, 0x5b, 0xff}) // 3,500.3 m.
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := dem.DecodeTerrainRGB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := g.Elevation(0, 0); got != 0 {
+		t.Errorf("(0, 0): %v, want 0", got)
+	}
+	if got := g.Elevation(1, 0); math.Abs(got-3500.3) > 1e-3 {
+		t.Errorf("(1, 0): %v, want 3500.3", got)
+	}
+	if got := g.Elevation(2, 0); !math.IsNaN(got) {
+		t.Errorf("transparent pixel: %v, want NaN", got)
+	}
+}
+
+func TestDepthMap(t *testing.T) {
+	g := dem.NewGrid(image.Rect(0, 0, 5, 1))
+	copy(g.Pix, []float32{-100, 0, 50, 100, float32(math.NaN())})
+
+	dm := dem.NewDepthMap(g, 20)
+	if (dm.Low != -100) || (dm.High != 100) {
+		t.Fatalf("range [%v, %v]", dm.Low, dm.High)
+	}
+
+	tests := []struct {
+		name string
+		low  float64
+		high float64
+		ex   float64
+		want []float64
+	}{
+		{name: "Range", low: -100, high: 100, want: []float64{0, 10, 15, 20, 0}},
+		{name: "Clamped", low: 0, high: 50, want: []float64{0, 0, 20, 20, 0}},
+		{name: "Exaggerated", low: -100, high: 100, ex: 2, want: []float64{0, 20, 30, 40, 0}},
+		{name: "Empty", low: 100, high: 100, want: []float64{0, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dm.Low, dm.High, dm.Exaggeration = test.low, test.high, test.ex
+			for x, want := range test.want {
+				if got := dm.AtF(x, 0); got != want {
+					t.Errorf("%v: %v, want %v", x, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package dem
+
+import (
+	"image"
+	"image/color"
+	"io"
+
+	_ "image/png"
+)
+
+// TerrainRGB returns a Grid of the elevations in a Mapbox-style
+// Terrain-RGB image, such as a map tile. The elevation of each pixel
+// is packed into 24 bits of its red, green, and blue channels, in
+// tenths of a meter above -10,000 meters. Transparent pixels have no
+// data.
+func TerrainRGB(img image.Image) *Grid {
+	r := img.Bounds()
+	g := NewGrid(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := g.Pix[g.PixOffset(r.Min.X, y):]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			v := uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+			row[x-r.Min.X] = float32(-10000 + float64(v)/10)
+		}
+	}
+	return g
+}
+
+// DecodeTerrainRGB decodes a Terrain-RGB image from r and returns its
+// elevations as with TerrainRGB. Terrain-RGB images are usually PNG
+// files, which can always be decoded, but any format that is
+// registered with the image package can be.
+func DecodeTerrainRGB(r io.Reader) (*Grid, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return TerrainRGB(img), nil
+}
@@ -0,0 +1,147 @@
+package sirdsc
+
+import (
+	"image"
+)
+
+// Depth is an in-memory DepthMap, analogous to image.Gray16. Depths
+// are stored as float32s, so it can hold fractional depths, and it
+// implements DepthMapF. Reading rows of depths from a Depth doesn't
+// require any conversion, making it the fastest kind of DepthMap to
+// generate a stereogram from.
+type Depth struct {
+	// Pix holds the depths. The depth at (x, y) starts at
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []float32
+
+	// Stride is the Pix stride between vertically adjacent pixels.
+	Stride int
+
+	// Rect is the depth map's bounds.
+	Rect image.Rectangle
+}
+
+// NewDepth returns a new Depth with the given bounds. All of its
+// depths are zero.
+func NewDepth(r image.Rectangle) *Depth {
+	return &Depth{
+		Pix:    make([]float32, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// Materialize evaluates every depth of dm once and returns the result
+// as a Depth with the same bounds. If dm is a DepthMapF, its
+// fractional depths are kept. This is useful for depth maps that are
+// expensive to evaluate, as generating a stereogram can read each
+// depth many times.
+func Materialize(dm DepthMap) *Depth {
+	r := dm.Bounds()
+	d := NewDepth(r)
+
+	row := make([]float64, r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		readDepthRowF(dm, row, r.Min.X, y)
+		dst := d.Pix[d.PixOffset(r.Min.X, y):]
+		for i, v := range row {
+			dst[i] = float32(v)
+		}
+	}
+
+	return d
+}
+
+// Bounds returns the bounds of the depth map.
+func (d *Depth) Bounds() image.Rectangle {
+	return d.Rect
+}
+
+// At returns the depth at (x, y), truncated to an integer. Points
+// outside of the bounds have a depth of zero.
+func (d *Depth) At(x, y int) int {
+	return int(d.AtF(x, y))
+}
+
+// AtF returns the depth at (x, y). Points outside of the bounds have a
+// depth of zero.
+func (d *Depth) AtF(x, y int) float64 {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return 0
+	}
+	return float64(d.Pix[d.PixOffset(x, y)])
+}
+
+// PixOffset returns the index of the element of Pix that corresponds
+// to the depth at (x, y).
+func (d *Depth) PixOffset(x, y int) int {
+	return (y-d.Rect.Min.Y)*d.Stride + (x - d.Rect.Min.X)
+}
+
+// Set sets the depth at (x, y). It does nothing if (x, y) is outside
+// of the bounds.
+func (d *Depth) Set(x, y int, depth float64) {
+	if !(image.Point{x, y}.In(d.Rect)) {
+		return
+	}
+	d.Pix[d.PixOffset(x, y)] = float32(depth)
+}
+
+// Fill sets every depth inside of r to depth.
+func (d *Depth) Fill(r image.Rectangle, depth float64) {
+	r = r.Intersect(d.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := d.PixOffset(r.Min.X, y)
+		row := d.Pix[i : i+r.Dx()]
+		for x := range row {
+			row[x] = float32(depth)
+		}
+	}
+}
+
+// SubImage returns a depth map representing the portion of d visible
+// through r. The returned value shares depths with the original.
+func (d *Depth) SubImage(r image.Rectangle) *Depth {
+	r = r.Intersect(d.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed
+	// to be inside either r1 or r2 if the intersection is empty.
+	// Without explicitly checking for this, the Pix[i:] expression
+	// below can panic.
+	if r.Empty() {
+		return &Depth{}
+	}
+	i := d.PixOffset(r.Min.X, r.Min.Y)
+	return &Depth{
+		Pix:    d.Pix[i:],
+		Stride: d.Stride,
+		Rect:   r,
+	}
+}
+
+func (d *Depth) depthRow(dst []int, x, y int) {
+	depthRow(d, dst, x, y, func(v float32) int { return int(v) })
+}
+
+func (d *Depth) depthRowF(dst []float64, x, y int) {
+	depthRow(d, dst, x, y, func(v float32) float64 { return float64(v) })
+}
+
+// depthRow fills dst with the depths of d from (x, y) to
+// (x+len(dst)-1, y), converted with conv.
+func depthRow[T int | float64](d *Depth, dst []T, x, y int, conv func(float32) T) {
+	clear(dst)
+	if (y < d.Rect.Min.Y) || (y >= d.Rect.Max.Y) {
+		return
+	}
+
+	x0 := max(x, d.Rect.Min.X)
+	x1 := min(x+len(dst), d.Rect.Max.X)
+	if x0 >= x1 {
+		return
+	}
+
+	i := d.PixOffset(x0, y)
+	for j, v := range d.Pix[i : i+x1-x0] {
+		dst[x0-x+j] = conv(v)
+	}
+}
@@ -0,0 +1,375 @@
+// Package depth provides procedurally generated depth maps of simple
+// shapes. They can be used directly or combined with the combinators
+// in the sirdsc package, such as sirdsc.Max, to build up scenes.
+//
+// Every shape is a sirdsc.DepthMapF, so curved surfaces keep their
+// fractional depths, and every shape has a depth of zero outside of
+// itself. Shapes are sampled at the centers of pixels, so a shape
+// centered on the point (x, y) is centered on the corner between the
+// pixels at (x-1, y-1) and (x, y).
+package depth
+
+import (
+	"image"
+	"math"
+)
+
+// center returns the offset of the center of the pixel at (x, y) from
+// c.
+func center(c image.Point, x, y int) (dx, dy float64) {
+	return float64(x-c.X) + 0.5, float64(y-c.Y) + 0.5
+}
+
+// dist returns the distance from c to the center of the pixel at
+// (x, y).
+func dist(c image.Point, x, y int) float64 {
+	return math.Hypot(center(c, x, y))
+}
+
+// circleBounds returns the bounds of a circle with radius r centered
+// on c.
+func circleBounds(c image.Point, r float64) image.Rectangle {
+	n := int(math.Ceil(r))
+	return image.Rect(c.X-n, c.Y-n, c.X+n, c.Y+n)
+}
+
+// round returns the height of a point on a round profile, such as the
+// cross section of a sphere, that is a fraction t of the way from its
+// middle to its edge.
+func round(height, t float64) float64 {
+	if (t < 0) || (t >= 1) {
+		return 0
+	}
+	return height * math.Sqrt(1-t*t)
+}
+
+// rotate rotates (x, y) by angle radians.
+func rotate(x, y, angle float64) (float64, float64) {
+	sin, cos := math.Sincos(angle)
+	return x*cos - y*sin, x*sin + y*cos
+}
+
+// Sphere is the visible half of a sphere, a hemisphere bulging towards
+// the viewer, with its base on the zero plane.
+type Sphere struct {
+	Center image.Point
+	Radius float64
+
+	// Height is the depth of the front of the sphere. If it is equal
+	// to Radius, the sphere is round. Otherwise, it is squashed or
+	// stretched towards the viewer.
+	Height float64
+}
+
+func (s Sphere) Bounds() image.Rectangle { // nolint
+	return circleBounds(s.Center, s.Radius)
+}
+
+func (s Sphere) At(x, y int) int { // nolint
+	return int(s.AtF(x, y))
+}
+
+func (s Sphere) AtF(x, y int) float64 { // nolint
+	return round(s.Height, dist(s.Center, x, y)/s.Radius)
+}
+
+// Cone is a cone pointing towards the viewer with its base on the zero
+// plane.
+type Cone struct {
+	Center image.Point
+	Radius float64
+
+	// Height is the depth of the tip of the cone.
+	Height float64
+}
+
+func (c Cone) Bounds() image.Rectangle { // nolint
+	return circleBounds(c.Center, c.Radius)
+}
+
+func (c Cone) At(x, y int) int { // nolint
+	return int(c.AtF(x, y))
+}
+
+func (c Cone) AtF(x, y int) float64 { // nolint
+	t := dist(c.Center, x, y) / c.Radius
+	if t >= 1 {
+		return 0
+	}
+	return c.Height * (1 - t)
+}
+
+// Cylinder is a cylinder lying on its side on the zero plane.
+type Cylinder struct {
+	// Center is the middle of the cylinder's axis.
+	Center image.Point
+
+	// Radius is the radius of the cylinder, and Length is the length
+	// of its axis.
+	Radius float64
+	Length float64
+
+	// Angle is the angle of the cylinder's axis in radians, measured
+	// clockwise from horizontal.
+	Angle float64
+
+	// Height is the depth of the front of the cylinder.
+	Height float64
+}
+
+func (c Cylinder) Bounds() image.Rectangle { // nolint
+	sin, cos := math.Sincos(c.Angle)
+	hw := math.Abs(cos)*c.Length/2 + math.Abs(sin)*c.Radius
+	hh := math.Abs(sin)*c.Length/2 + math.Abs(cos)*c.Radius
+
+	// Sincos isn't exact for right angles, so allow for a little bit
+	// of error to keep from adding a pixel to the bounds.
+	const e = 1e-9
+	w, h := int(math.Ceil(hw-e)), int(math.Ceil(hh-e))
+	return image.Rect(c.Center.X-w, c.Center.Y-h, c.Center.X+w, c.Center.Y+h)
+}
+
+func (c Cylinder) At(x, y int) int { // nolint
+	return int(c.AtF(x, y))
+}
+
+func (c Cylinder) AtF(x, y int) float64 { // nolint
+	dx, dy := center(c.Center, x, y)
+	along, across := rotate(dx, dy, -c.Angle)
+	if math.Abs(along) > c.Length/2 {
+		return 0
+	}
+	return round(c.Height, math.Abs(across)/c.Radius)
+}
+
+// Torus is a ring-shaped torus lying flat on the zero plane.
+type Torus struct {
+	Center image.Point
+
+	// Radius is the distance from the center of the torus to the
+	// middle of its tube, and Thickness is the radius of the tube.
+	Radius    float64
+	Thickness float64
+
+	// Height is the depth of the front of the tube.
+	Height float64
+}
+
+func (t Torus) Bounds() image.Rectangle { // nolint
+	return circleBounds(t.Center, t.Radius+t.Thickness)
+}
+
+func (t Torus) At(x, y int) int { // nolint
+	return int(t.AtF(x, y))
+}
+
+func (t Torus) AtF(x, y int) float64 { // nolint
+	q := math.Abs(dist(t.Center, x, y) - t.Radius)
+	return round(t.Height, q/t.Thickness)
+}
+
+// Box is a box with a flat top and, optionally, bevelled edges.
+type Box struct {
+	Rect image.Rectangle
+
+	// Height is the depth of the top of the box.
+	Height float64
+
+	// Bevel is the width of the sloped edges around the top of the
+	// box. If it is zero, the sides of the box are vertical.
+	Bevel float64
+}
+
+func (b Box) Bounds() image.Rectangle { // nolint
+	return b.Rect
+}
+
+func (b Box) At(x, y int) int { // nolint
+	return int(b.AtF(x, y))
+}
+
+func (b Box) AtF(x, y int) float64 { // nolint
+	if !(image.Point{x, y}.In(b.Rect)) {
+		return 0
+	}
+	if b.Bevel <= 0 {
+		return b.Height
+	}
+
+	edge := min(
+		float64(x-b.Rect.Min.X)+0.5,
+		float64(b.Rect.Max.X-x)-0.5,
+		float64(y-b.Rect.Min.Y)+0.5,
+		float64(b.Rect.Max.Y-y)-0.5,
+	)
+	return b.Height * min(edge/b.Bevel, 1)
+}
+
+// Plane is a flat, possibly tilted, plane filling a rectangle.
+type Plane struct {
+	Rect image.Rectangle
+
+	// Depth is the depth of the plane at the center of Rect.
+	Depth float64
+
+	// SlopeX and SlopeY are the changes in depth for every pixel to
+	// the right and down, respectively, tilting the plane.
+	SlopeX, SlopeY float64
+}
+
+func (p Plane) Bounds() image.Rectangle { // nolint
+	return p.Rect
+}
+
+func (p Plane) At(x, y int) int { // nolint
+	return int(p.AtF(x, y))
+}
+
+func (p Plane) AtF(x, y int) float64 { // nolint
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0
+	}
+	c := p.Rect.Min.Add(p.Rect.Max).Div(2)
+	dx, dy := center(c, x, y)
+	return p.Depth + p.SlopeX*dx + p.SlopeY*dy
+}
+
+// Ramp is a linear gradient of depths filling a rectangle.
+type Ramp struct {
+	Rect image.Rectangle
+
+	// From is the depth on the side of Rect that the ramp starts from,
+	// and To is the depth on the opposite side.
+	From, To float64
+
+	// Angle is the direction that the ramp goes in, in radians,
+	// measured clockwise from left to right.
+	Angle float64
+}
+
+func (r Ramp) Bounds() image.Rectangle { // nolint
+	return r.Rect
+}
+
+func (r Ramp) At(x, y int) int { // nolint
+	return int(r.AtF(x, y))
+}
+
+func (r Ramp) AtF(x, y int) float64 { // nolint
+	if !(image.Point{x, y}.In(r.Rect)) {
+		return 0
+	}
+
+	// The ramp spans the projection of the rectangle onto its
+	// direction.
+	c := r.Rect.Min.Add(r.Rect.Max).Div(2)
+	dx, dy := center(c, x, y)
+	sin, cos := math.Sincos(r.Angle)
+	half := (math.Abs(cos)*float64(r.Rect.Dx()) + math.Abs(sin)*float64(r.Rect.Dy())) / 2
+	t := (dx*cos + dy*sin + half) / (2 * half)
+	return r.From + (r.To-r.From)*min(max(t, 0), 1)
+}
+
+// Waves are parallel sine waves filling a rectangle. Their depths
+// range from zero to Height.
+type Waves struct {
+	Rect image.Rectangle
+
+	// Wavelength is the distance between the crests of the waves, in
+	// pixels.
+	Wavelength float64
+
+	// Angle is the direction that the waves travel in, in radians,
+	// measured clockwise from left to right.
+	Angle float64
+
+	// Phase shifts the waves along their direction, in radians.
+	Phase float64
+
+	Height float64
+}
+
+func (w Waves) Bounds() image.Rectangle { // nolint
+	return w.Rect
+}
+
+func (w Waves) At(x, y int) int { // nolint
+	return int(w.AtF(x, y))
+}
+
+func (w Waves) AtF(x, y int) float64 { // nolint
+	if !(image.Point{x, y}.In(w.Rect)) {
+		return 0
+	}
+	dx, dy := center(w.Rect.Min, x, y)
+	t, _ := rotate(dx, dy, -w.Angle)
+	return wave(w.Height, 2*math.Pi*t/w.Wavelength+w.Phase)
+}
+
+// Ripples are circular sine waves spreading out from a point, like
+// ripples on water. Their depths range from zero to Height, getting
+// smaller towards Radius.
+type Ripples struct {
+	Center image.Point
+
+	// Radius is the distance from Center at which the ripples end.
+	Radius float64
+
+	// Wavelength is the distance between the crests of the ripples, in
+	// pixels.
+	Wavelength float64
+
+	// Phase shifts the ripples outwards, in radians.
+	Phase float64
+
+	Height float64
+}
+
+func (r Ripples) Bounds() image.Rectangle { // nolint
+	return circleBounds(r.Center, r.Radius)
+}
+
+func (r Ripples) At(x, y int) int { // nolint
+	return int(r.AtF(x, y))
+}
+
+func (r Ripples) AtF(x, y int) float64 { // nolint
+	d := dist(r.Center, x, y)
+	if d >= r.Radius {
+		return 0
+	}
+	return wave(r.Height, r.Phase-2*math.Pi*d/r.Wavelength) * (1 - d/r.Radius)
+}
+
+// wave returns the depth of a sine wave with a trough of zero and a
+// crest of height at the angle a, in radians.
+func wave(height, a float64) float64 {
+	return height * (1 + math.Cos(a)) / 2
+}
+
+// Annulus is a flat ring.
+type Annulus struct {
+	Center image.Point
+
+	// Inner and Outer are the radii of the inside and outside edges of
+	// the ring.
+	Inner, Outer float64
+
+	Height float64
+}
+
+func (a Annulus) Bounds() image.Rectangle { // nolint
+	return circleBounds(a.Center, a.Outer)
+}
+
+func (a Annulus) At(x, y int) int { // nolint
+	return int(a.AtF(x, y))
+}
+
+func (a Annulus) AtF(x, y int) float64 { // nolint
+	d := dist(a.Center, x, y)
+	if (d < a.Inner) || (d >= a.Outer) {
+		return 0
+	}
+	return a.Height
+}
@@ -0,0 +1,143 @@
+package depth_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/depth"
+)
+
+func TestShapes(t *testing.T) {
+	type point struct {
+		x, y int
+		want float64
+	}
+
+	tests := []struct {
+		name   string
+		dm     sirdsc.DepthMapF
+		bounds image.Rectangle
+		points []point
+	}{
+		{
+			name:   "Sphere",
+			dm:     depth.Sphere{Center: image.Pt(10, 10), Radius: 5, Height: 20},
+			bounds: image.Rect(5, 5, 15, 15),
+			points: []point{
+				{10, 10, 20 * math.Sqrt(1-0.5/25)},
+				{12, 9, 20 * math.Sqrt(1-(2.5*2.5+0.5*0.5)/25)},
+				{15, 10, 0},
+				{0, 0, 0},
+			},
+		},
+		{
+			name:   "Cone",
+			dm:     depth.Cone{Center: image.Pt(0, 0), Radius: 10, Height: 10},
+			bounds: image.Rect(-10, -10, 10, 10),
+			points: []point{
+				{3, -1, 10 - math.Hypot(3.5, 0.5)},
+				{-11, 0, 0},
+			},
+		},
+		{
+			name:   "Cylinder",
+			dm:     depth.Cylinder{Center: image.Pt(0, 0), Radius: 4, Length: 20, Angle: math.Pi / 2, Height: 8},
+			bounds: image.Rect(-4, -10, 4, 10),
+			points: []point{
+				{1, 7, 8 * math.Sqrt(1-1.5*1.5/16)},
+				{-1, 9, 8 * math.Sqrt(1-0.5*0.5/16)},
+				{0, 10, 0},
+				{4, 0, 0},
+			},
+		},
+		{
+			name:   "Torus",
+			dm:     depth.Torus{Center: image.Pt(0, 0), Radius: 10.5, Thickness: 2, Height: 4},
+			bounds: image.Rect(-13, -13, 13, 13),
+			points: []point{
+				{10, -1, 4 * math.Sqrt(1-math.Pow(math.Hypot(10.5, 0.5)-10.5, 2)/4)},
+				{12, -1, 4 * math.Sqrt(1-math.Pow(math.Hypot(12.5, 0.5)-10.5, 2)/4)},
+				{0, 0, 0},
+			},
+		},
+		{
+			name:   "Box",
+			dm:     depth.Box{Rect: image.Rect(0, 0, 10, 10), Height: 6, Bevel: 3},
+			bounds: image.Rect(0, 0, 10, 10),
+			points: []point{
+				{0, 5, 1},
+				{8, 5, 3},
+				{5, 5, 6},
+				{10, 5, 0},
+			},
+		},
+		{
+			name:   "Plane",
+			dm:     depth.Plane{Rect: image.Rect(0, 0, 10, 10), Depth: 5, SlopeX: 1, SlopeY: -0.5},
+			bounds: image.Rect(0, 0, 10, 10),
+			points: []point{
+				{5, 5, 5.25},
+				{0, 0, 5 - 4.5 + 2.25},
+				{-1, 0, 0},
+			},
+		},
+		{
+			name:   "Ramp",
+			dm:     depth.Ramp{Rect: image.Rect(0, 0, 10, 4), From: 0, To: 10, Angle: math.Pi},
+			bounds: image.Rect(0, 0, 10, 4),
+			points: []point{
+				{0, 0, 9.5},
+				{9, 3, 0.5},
+			},
+		},
+		{
+			name:   "Waves",
+			dm:     depth.Waves{Rect: image.Rect(0, 0, 8, 8), Wavelength: 4, Phase: -math.Pi / 4, Height: 2},
+			bounds: image.Rect(0, 0, 8, 8),
+			points: []point{
+				{0, 3, 2},
+				{2, 0, 0},
+				{4, 7, 2},
+			},
+		},
+		{
+			name:   "Ripples",
+			dm:     depth.Ripples{Center: image.Pt(0, 0), Radius: 20, Wavelength: 5, Height: 10},
+			bounds: image.Rect(-20, -20, 20, 20),
+			points: []point{
+				{2, -3, 10 * (1 + math.Cos(2*math.Pi*math.Hypot(2.5, 2.5)/5)) / 2 * (1 - math.Hypot(2.5, 2.5)/20)},
+				{4, -1, 10 * (1 - math.Hypot(4.5, 0.5)/20) * (1 + math.Cos(2*math.Pi*math.Hypot(4.5, 0.5)/5)) / 2},
+				{20, 0, 0},
+			},
+		},
+		{
+			name:   "Annulus",
+			dm:     depth.Annulus{Center: image.Pt(0, 0), Inner: 3, Outer: 6, Height: 7},
+			bounds: image.Rect(-6, -6, 6, 6),
+			points: []point{
+				{0, 0, 0},
+				{3, 0, 7},
+				{-5, -1, 7},
+				{5, 5, 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if b := test.dm.Bounds(); b != test.bounds {
+				t.Errorf("bounds: %v, want %v", b, test.bounds)
+			}
+			for _, p := range test.points {
+				if got := test.dm.AtF(p.x, p.y); math.Abs(got-p.want) > 1e-9 {
+					t.Errorf("AtF(%v, %v) == %v, want %v", p.x, p.y, got, p.want)
+				}
+				if got := test.dm.At(p.x, p.y); got != int(test.dm.AtF(p.x, p.y)) {
+					t.Errorf("At(%v, %v) == %v", p.x, p.y, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package sirdsc_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestDepth(t *testing.T) {
+	d := sirdsc.NewDepth(image.Rect(-2, -2, 8, 8))
+	d.Set(3, 4, 5.5)
+	d.Set(20, 20, 1)
+	d.Fill(image.Rect(-5, -5, 0, 0), -2)
+
+	tests := []struct {
+		x, y int
+		want float64
+	}{
+		{3, 4, 5.5},
+		{4, 3, 0},
+		{-1, -1, -2},
+		{0, 0, 0},
+		{20, 20, 0},
+	}
+	for _, test := range tests {
+		if got := d.AtF(test.x, test.y); got != test.want {
+			t.Errorf("AtF(%v, %v) == %v, want %v", test.x, test.y, got, test.want)
+		}
+		if got := d.At(test.x, test.y); got != int(test.want) {
+			t.Errorf("At(%v, %v) == %v, want %v", test.x, test.y, got, int(test.want))
+		}
+	}
+
+	sub := d.SubImage(image.Rect(2, 2, 5, 5))
+	if sub.Bounds() != image.Rect(2, 2, 5, 5) {
+		t.Fatalf("SubImage bounds: %v", sub.Bounds())
+	}
+	sub.Set(2, 2, 7)
+	if got := d.AtF(2, 2); got != 7 {
+		t.Fatalf("SubImage doesn't share depths: %v", got)
+	}
+	if got := sub.AtF(-1, -1); got != 0 {
+		t.Fatalf("SubImage.AtF(-1, -1) == %v, want 0", got)
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	r := image.Rect(3, 5, 50, 40)
+	dm := testDepthMap(r)
+	d := sirdsc.Materialize(dm)
+	if d.Bounds() != r {
+		t.Fatalf("bounds: %v", d.Bounds())
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			want := float64(float32(dm.AtF(x, y)))
+			if got := d.AtF(x, y); got != want {
+				t.Fatalf("AtF(%v, %v) == %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package sirdsc
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"math"
@@ -20,6 +21,17 @@ type DepthMap interface {
 	At(x, y int) int
 }
 
+// A DepthMapF is a DepthMap that can also return fractional depths.
+// When a stereogram is oversampled, fractional depths allow for
+// separations that lie between whole pixels.
+type DepthMapF interface {
+	DepthMap
+
+	// AtF returns the depth at the given (x, y) coordinates without
+	// rounding it to an integer. It must be consistent with At.
+	AtF(x, y int) float64
+}
+
 // DefaultMaxImageDepth is the maximum depth used by ImageDepthMap if none is specified.
 const DefaultMaxImageDepth = 40
 
@@ -27,20 +39,27 @@ const DefaultMaxImageDepth = 40
 // be used as a DepthMap. It determines depth information from the
 // values of the underlying pixels, considering higher value pixels to
 // be closer and lower value pixels to be further away. Solid black
-// pixels are considered to have a depth of zero. Alpha information is
-// ignored.
+// pixels are considered to have a depth of zero. The value of a pixel
+// is read from the channel selected by Channel. Unless AlphaMask is
+// true, alpha information is otherwise ignored.
+//
+// Pixel values are read with the full 16 bits of precision that
+// color.Color provides, so 16-bit images, such as *image.Gray16 and
+// *image.RGBA64, produce smooth fractional depths rather than being
+// limited to 256 levels.
 type ImageDepthMap struct {
 	// The image.Image to pull pixel data from.
 	Image image.Image
 
 	// Max is the maximum depth that can be calculated from the pixels.
 	// In other words, a pixel with a value of 0 yields a depth of 0,
-	// while a pixel with a value of 255 yields this depth.
+	// while a pixel with the maximum value yields this depth.
 	//
 	// If Max is zero, DefaultMaxImageDepth is used instead.
 	Max int
 
-	// If flat is true, all non-black pixels are considered to be Max.
+	// If flat is true, all pixels with a non-zero value are
+	// considered to be Max.
 	Flat bool
 
 	// If Inverse is true, pixel values are considered to be the inverse
@@ -48,6 +67,76 @@ type ImageDepthMap struct {
 	// be closer, while higher value pixels are considered to be further
 	// away.
 	Inverse bool
+
+	// If Signed is true, mid-gray pixels are considered to have a depth
+	// of zero instead of black ones. A pixel with a value of 0 yields a
+	// depth of -Max, placing it behind the background, while a pixel
+	// with the maximum value yields a depth of Max. This allows a single
+	// depth map to contain both recessed and raised areas. Mid-gray
+	// falls between two 8-bit values, so values within half of an 8-bit
+	// step of it, including both 127 and 128, have a depth of zero. When
+	// Flat is also true, pixels that don't have a depth of zero are
+	// considered to be either -Max or Max.
+	Signed bool
+
+	// Channel is the channel that the value of each pixel is read from.
+	// The default, ChannelMax, uses the largest of the red, green, and
+	// blue channels.
+	Channel Channel
+
+	// If AlphaMask is true, transparent pixels are considered to be on
+	// the zero plane, whatever their color, and the depths of
+	// translucent pixels are scaled towards it by their alpha. The
+	// color channels are un-premultiplied before they are read.
+	AlphaMask bool
+
+	// Transfer, if not nil, is applied to the value of each pixel,
+	// normalized to the range [0, 1], before it is converted to a
+	// depth. See Transfer for details.
+	Transfer Transfer
+}
+
+// A Channel selects how ImageDepthMap reads the value of a pixel.
+type Channel int
+
+const (
+	// ChannelMax uses the largest of the red, green, and blue channels.
+	ChannelMax Channel = iota
+
+	// ChannelLuma uses the Rec. 709 luma of the red, green, and blue
+	// channels.
+	ChannelLuma
+
+	// ChannelRed uses the red channel.
+	ChannelRed
+
+	// ChannelGreen uses the green channel.
+	ChannelGreen
+
+	// ChannelBlue uses the blue channel.
+	ChannelBlue
+
+	// ChannelAlpha uses the alpha channel.
+	ChannelAlpha
+)
+
+func (c Channel) String() string {
+	switch c {
+	case ChannelMax:
+		return "max"
+	case ChannelLuma:
+		return "luma"
+	case ChannelRed:
+		return "red"
+	case ChannelGreen:
+		return "green"
+	case ChannelBlue:
+		return "blue"
+	case ChannelAlpha:
+		return "alpha"
+	default:
+		return fmt.Sprintf("Channel(%d)", int(c))
+	}
 }
 
 // Bounds returns the same boundries as the underlying image.
@@ -56,27 +145,223 @@ func (dm ImageDepthMap) Bounds() image.Rectangle {
 }
 
 func (dm ImageDepthMap) At(x, y int) int { // nolint
+	return int(dm.AtF(x, y))
+}
+
+// AtF returns the depth at (x, y) without truncating it to an integer.
+func (dm ImageDepthMap) AtF(x, y int) float64 {
+	r, g, b, a := dm.Image.At(x, y).RGBA()
+	return dm.depth(r, g, b, a)
+}
+
+// value returns the value of a pixel from its alpha-premultiplied
+// 16-bit red, green, blue, and alpha values.
+func (dm ImageDepthMap) value(r, g, b, a uint32) float64 {
+	if dm.AlphaMask && (a != 0) && (a != 0xffff) {
+		r = r * 0xffff / a
+		g = g * 0xffff / a
+		b = b * 0xffff / a
+	}
+
+	switch dm.Channel {
+	case ChannelLuma:
+		return 0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)
+	case ChannelRed:
+		return float64(r)
+	case ChannelGreen:
+		return float64(g)
+	case ChannelBlue:
+		return float64(b)
+	case ChannelAlpha:
+		return float64(a)
+	default:
+		return float64(max(r, g, b))
+	}
+}
+
+// depth calculates the depth of a pixel from its alpha-premultiplied
+// 16-bit red, green, blue, and alpha values.
+func (dm ImageDepthMap) depth(r, g, b, a uint32) float64 {
+	if dm.AlphaMask && (a == 0) {
+		return 0
+	}
+
+	d := dm.valueDepth(dm.value(r, g, b, a))
+	if dm.AlphaMask {
+		d = d * float64(a) / math.MaxUint16
+	}
+	return d
+}
+
+// valueDepth converts the value of a pixel to a depth.
+func (dm ImageDepthMap) valueDepth(v float64) float64 {
 	max := dm.Max
 	if max <= 0 {
 		max = DefaultMaxImageDepth
 	}
 
-	c := color.RGBAModel.Convert(dm.Image.At(x, y))
-	tr, tg, tb, _ := c.RGBA()
-	r := uint8(tr)
-	g := uint8(tg)
-	b := uint8(tb)
-
-	v := math.Max(float64(g), math.Max(float64(b), float64(r)))
-	d := v * float64(max) / math.MaxUint8
+	t := v / math.MaxUint16
+	d := v * float64(max) / math.MaxUint16
+	if dm.Transfer != nil {
+		t = dm.Transfer(t)
+		d = t * float64(max)
+	}
+	if dm.Signed {
+		// Mid-gray falls between two 8-bit values, so everything within
+		// half of an 8-bit step of it, including both of those values,
+		// has a depth of zero.
+		if math.Abs(t-0.5) <= 0.5/0xff+1e-9 {
+			return 0
+		}
+		d = 2*d - float64(max)
+	}
 
 	if (dm.Flat) && (d != 0) {
-		return max
+		if d < 0 {
+			return -float64(max)
+		}
+		return float64(max)
 	}
 
 	if dm.Inverse {
-		d = float64(max) - d
+		if dm.Signed {
+			d = -d
+		} else {
+			d = float64(max) - d
+		}
 	}
 
-	return int(d)
+	return d
+}
+
+func (dm ImageDepthMap) depthRow(dst []int, x, y int) {
+	imageDepthRow(dm, dst, x, y, func(d float64) int { return int(d) })
+}
+
+func (dm ImageDepthMap) depthRowF(dst []float64, x, y int) {
+	imageDepthRow(dm, dst, x, y, func(d float64) float64 { return d })
+}
+
+// imageDepthRow fills dst with the depths of dm from (x, y) to
+// (x+len(dst)-1, y), converted with conv.
+func imageDepthRow[T int | float64](dm ImageDepthMap, dst []T, x, y int, conv func(float64) T) {
+	switch img := dm.Image.(type) {
+	// The depths of pixels outside of the image match the colors that
+	// the image's At method returns for them.
+	case *image.Gray:
+		for i := range dst {
+			p := image.Point{x + i, y}
+			if !p.In(img.Rect) {
+				dst[i] = conv(dm.depth(0, 0, 0, 0xffff))
+				continue
+			}
+			v := uint32(img.Pix[img.PixOffset(p.X, p.Y)]) * 0x101
+			dst[i] = conv(dm.depth(v, v, v, 0xffff))
+		}
+
+	case *image.Gray16:
+		for i := range dst {
+			p := image.Point{x + i, y}
+			if !p.In(img.Rect) {
+				dst[i] = conv(dm.depth(0, 0, 0, 0xffff))
+				continue
+			}
+			j := img.PixOffset(p.X, p.Y)
+			v := uint32(img.Pix[j])<<8 | uint32(img.Pix[j+1])
+			dst[i] = conv(dm.depth(v, v, v, 0xffff))
+		}
+
+	case *image.RGBA:
+		for i := range dst {
+			p := image.Point{x + i, y}
+			if !p.In(img.Rect) {
+				dst[i] = conv(dm.depth(0, 0, 0, 0))
+				continue
+			}
+			j := img.PixOffset(p.X, p.Y)
+			s := img.Pix[j : j+4 : j+4]
+			dst[i] = conv(dm.depth(uint32(s[0])*0x101, uint32(s[1])*0x101, uint32(s[2])*0x101, uint32(s[3])*0x101))
+		}
+
+	case *image.RGBA64:
+		for i := range dst {
+			p := image.Point{x + i, y}
+			if !p.In(img.Rect) {
+				dst[i] = conv(dm.depth(0, 0, 0, 0))
+				continue
+			}
+			j := img.PixOffset(p.X, p.Y)
+			s := img.Pix[j : j+8 : j+8]
+			r := uint32(s[0])<<8 | uint32(s[1])
+			g := uint32(s[2])<<8 | uint32(s[3])
+			b := uint32(s[4])<<8 | uint32(s[5])
+			a := uint32(s[6])<<8 | uint32(s[7])
+			dst[i] = conv(dm.depth(r, g, b, a))
+		}
+
+	case *image.NRGBA:
+		for i := range dst {
+			p := image.Point{x + i, y}
+			if !p.In(img.Rect) {
+				dst[i] = conv(dm.depth(0, 0, 0, 0))
+				continue
+			}
+			j := img.PixOffset(p.X, p.Y)
+			s := img.Pix[j : j+4 : j+4]
+			r, g, b, a := color.NRGBA{s[0], s[1], s[2], s[3]}.RGBA()
+			dst[i] = conv(dm.depth(r, g, b, a))
+		}
+
+	default:
+		for i := range dst {
+			dst[i] = conv(dm.AtF(x+i, y))
+		}
+	}
+}
+
+// depthRower is implemented by depth maps that can read a whole row of
+// depths more efficiently than by calling At for each pixel.
+type depthRower interface {
+	// depthRow fills dst with the depths from (x, y) to
+	// (x+len(dst)-1, y).
+	depthRow(dst []int, x, y int)
+}
+
+// readDepthRow fills dst with the depths of dm from (x, y) to
+// (x+len(dst)-1, y).
+func readDepthRow(dm DepthMap, dst []int, x, y int) {
+	if dm, ok := dm.(depthRower); ok {
+		dm.depthRow(dst, x, y)
+		return
+	}
+
+	for i := range dst {
+		dst[i] = dm.At(x+i, y)
+	}
+}
+
+// depthRowerF is implemented by depth maps that can read a whole row
+// of fractional depths more efficiently than by calling AtF for each
+// pixel.
+type depthRowerF interface {
+	// depthRowF fills dst with the depths from (x, y) to
+	// (x+len(dst)-1, y).
+	depthRowF(dst []float64, x, y int)
+}
+
+// readDepthRowF is like readDepthRow, but it reads fractional depths if
+// dm is a DepthMapF.
+func readDepthRowF(dm DepthMap, dst []float64, x, y int) {
+	switch dm := dm.(type) {
+	case depthRowerF:
+		dm.depthRowF(dst, x, y)
+	case DepthMapF:
+		for i := range dst {
+			dst[i] = dm.AtF(x+i, y)
+		}
+	default:
+		for i := range dst {
+			dst[i] = float64(dm.At(x+i, y))
+		}
+	}
 }
@@ -1,11 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
-	"math"
 	"time"
 
 	"github.com/DeedleFake/sirdsc"
@@ -29,33 +29,21 @@ const (
 	FPSDelay = 5 * time.Second
 )
 
-// DepthMap is an implementation of sirdsc.DepthMap that 'draws'
-// objects as depths, rather than colors.
-type DepthMap struct {
+// Scene holds the objects in the game. It 'draws' them as depths,
+// rather than colors.
+type Scene struct {
 	Depth int
-	Rect  pixel.Rect
+	Rect  image.Rectangle
 
-	Obstacle pixel.Rect
+	Obstacle image.Rectangle
 }
 
-func (dm DepthMap) Bounds() image.Rectangle { // nolint
-	return image.Rect(0, 0, ScreenWidth, ScreenHeight)
-}
-
-func (dm DepthMap) At(x, y int) int { // nolint
-	v := pixel.V(float64(x), float64(y))
-
-	if dm.Rect.Contains(v) && dm.Obstacle.Contains(v) {
-		return int(math.Max(float64(dm.Depth), 10))
-	}
-	if dm.Rect.Contains(v) {
-		return dm.Depth
-	}
-	if dm.Obstacle.Contains(v) {
-		return 10
-	}
-
-	return 0
+// Draw draws the scene into dst.
+func (scene Scene) Draw(dst *sirdsc.Depth) {
+	dst.Fill(dst.Rect, 0)
+	dst.Fill(scene.Obstacle, 10)
+	dst.Fill(scene.Rect, float64(scene.Depth))
+	dst.Fill(scene.Rect.Intersect(scene.Obstacle), float64(max(scene.Depth, 10)))
 }
 
 type PictureImage pixel.PictureData
@@ -106,17 +94,26 @@ func main() {
 
 		out := (*PictureImage)(pixel.MakePictureData(win.Bounds()))
 
-		dm := DepthMap{
+		scene := Scene{
 			Depth: 10,
-			Rect:  pixel.R(100, 100, 200, 200),
+			Rect:  image.Rect(100, 100, 200, 200),
 
-			Obstacle: pixel.R(
+			Obstacle: image.Rect(
 				ScreenWidth/2-35,
 				ScreenHeight/2-35,
 				ScreenWidth/2+35,
 				ScreenHeight/2+35,
 			),
 		}
+		dm := sirdsc.NewDepth(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+
+		pool := sirdsc.NewPool(0)
+		defer pool.Close()
+
+		opts := sirdsc.Options{
+			PartSize: PartSize,
+			Pool:     pool,
+		}
 
 		seed := time.Now().UnixNano()
 
@@ -143,40 +140,40 @@ func main() {
 			}
 
 			if win.Pressed(pixel.KeyUp) {
-				dm.Rect.Min.Y -= 10
-				dm.Rect.Max.Y -= 10
+				scene.Rect = scene.Rect.Sub(image.Pt(0, 10))
 			}
 			if win.Pressed(pixel.KeyDown) {
-				dm.Rect.Min.Y += 10
-				dm.Rect.Max.Y += 10
+				scene.Rect = scene.Rect.Add(image.Pt(0, 10))
 			}
 			if win.Pressed(pixel.KeyLeft) {
-				dm.Rect.Min.X -= 10
-				dm.Rect.Max.X -= 10
+				scene.Rect = scene.Rect.Sub(image.Pt(10, 0))
 			}
 			if win.Pressed(pixel.KeyRight) {
-				dm.Rect.Min.X += 10
-				dm.Rect.Max.X += 10
+				scene.Rect = scene.Rect.Add(image.Pt(10, 0))
 			}
 
 			if win.Pressed(pixel.KeyW) {
-				dm.Depth--
+				scene.Depth--
 			}
 			if win.Pressed(pixel.KeyS) {
-				dm.Depth++
+				scene.Depth++
 			}
-			if dm.Depth < 5 {
-				dm.Depth = 5
+			if scene.Depth < 5 {
+				scene.Depth = 5
 			}
-			if dm.Depth > 20 {
-				dm.Depth = 20
+			if scene.Depth > 20 {
+				scene.Depth = 20
 			}
+			scene.Draw(dm)
 
 			if s := time.Now().UnixNano(); s-seed > int64(time.Second/30) {
 				seed = s
 			}
 
-			sirdsc.Generate(out, dm, sirdsc.RandImage{Seed: uint64(seed)}, PartSize)
+			err := sirdsc.GenerateContext(context.Background(), out, dm, sirdsc.RandImage{Seed: uint64(seed)}, &opts)
+			if err != nil {
+				log.Fatalf("Failed to generate SIRDS: %v", err)
+			}
 			s := pixel.NewSprite(out.PictureData(), out.PictureData().Bounds())
 			s.Draw(win, pixel.IM)
 
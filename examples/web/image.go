@@ -6,34 +6,185 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/color/palette"
 	"image/gif"
 	_ "image/jpeg"
 	"image/png"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DeedleFake/sirdsc"
+	_ "github.com/DeedleFake/sirdsc/netpbm"
+	"golang.org/x/image/font/opentype"
 	"golang.org/x/sync/errgroup"
 )
 
+// A Source is something that a stereogram can be generated from.
+type Source interface {
+	Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error
+}
+
 type Image interface {
 	image.Image
-	Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error
+	Source
 }
 
 type GenerateConfig struct {
-	Pattern  image.Image
-	PartSize int
-	MaxDepth int
-	Flat     bool
-	Inverse  bool
+	Pattern     image.Image
+	PartSize    int
+	MaxDepth    int
+	Flat        bool
+	Inverse     bool
+	Signed      bool
+	Channel     sirdsc.Channel
+	AlphaMask   bool
+	AutoLevels  bool
+	Equalize    bool
+	Curve       []sirdsc.CurvePoint
+	Gamma       float64
+	Posterize   int
+	Width       int
+	Height      int
+	Filter      sirdsc.Filter
+	Resample    sirdsc.ResampleMode
+	HSR         bool
+	Cross       bool
+	Geometry    *sirdsc.ViewingGeometry
+	Center      bool
+	Framing     sirdsc.Framing
+	Oversample  int
+	Font        *opentype.Font
+	TextSize    float64
+	Align       sirdsc.TextAlign
+	LineSpacing float64
+	Bevel       float64
+	Round       bool
+	Seed        uint64
+	T           float64
+}
+
+func (config *GenerateConfig) options() *sirdsc.Options {
+	algorithm := sirdsc.Copy
+	if config.HSR {
+		algorithm = sirdsc.HiddenSurface
+	}
+
+	mode := sirdsc.WallEyed
+	if config.Cross {
+		mode = sirdsc.CrossEyed
+	}
+
+	propagation := sirdsc.PropagateRight
+	if config.Center {
+		propagation = sirdsc.PropagateOut
+	}
+
+	return &sirdsc.Options{
+		PartSize:    config.PartSize,
+		Algorithm:   algorithm,
+		Mode:        mode,
+		Geometry:    config.Geometry,
+		MaxDepth:    config.MaxDepth,
+		Propagation: propagation,
+		Framing:     config.Framing,
+		Oversample:  config.Oversample,
+		Pool:        pool,
+	}
+}
+
+// depthMap returns the depth map for img, which is a frame of an image
+// with the bounds canvas. If the depth map is resampled, it is
+// resampled as though the whole canvas was being resampled, so its
+// bounds become the bounds of the resampled canvas.
+func (config *GenerateConfig) depthMap(img image.Image, canvas image.Rectangle) sirdsc.DepthMap {
+	dm := sirdsc.ImageDepthMap{
+		Image:     img,
+		Max:       config.MaxDepth,
+		Flat:      config.Flat,
+		Inverse:   config.Inverse,
+		Signed:    config.Signed,
+		Channel:   config.Channel,
+		AlphaMask: config.AlphaMask,
+	}
+
+	var transfers []sirdsc.Transfer
+	if config.AutoLevels {
+		transfers = append(transfers, dm.AutoLevels())
+	}
+	if config.Equalize {
+		transfers = append(transfers, dm.Equalize())
+	}
+	if len(config.Curve) > 0 {
+		transfers = append(transfers, sirdsc.Curve(config.Curve...))
+	}
+	if (config.Gamma > 0) && (config.Gamma != 1) {
+		transfers = append(transfers, sirdsc.Gamma(config.Gamma))
+	}
+	if config.Posterize >= 2 {
+		transfers = append(transfers, sirdsc.Posterize(config.Posterize))
+	}
+	if len(transfers) > 0 {
+		dm.Transfer = sirdsc.Compose(transfers...)
+	}
+
+	if img.Bounds() != canvas {
+		return config.resample(sirdsc.Add(dm, sirdsc.NewDepth(canvas)))
+	}
+	return config.resample(dm)
+}
+
+// resample resamples dm to the configured size, if there is one.
+func (config *GenerateConfig) resample(dm sirdsc.DepthMap) sirdsc.DepthMap {
+	if (config.Width <= 0) && (config.Height <= 0) {
+		return dm
+	}
+
+	size := image.Pt(config.Width, config.Height)
+	return sirdsc.Resample(dm, size, config.Filter, config.Resample)
+}
+
+// checkOutput returns an error if a generated image with the bounds r
+// would have more than maxPixels pixels.
+func checkOutput(r image.Rectangle) error {
+	if (r.Dx() > 0) && (r.Dy() > maxPixels/r.Dx()) {
+		return fmt.Errorf("%w: output of %vx%v is larger than %v pixels", errBadQuery, r.Dx(), r.Dy(), maxPixels)
+	}
+	return nil
+}
+
+// generatePNG generates a stereogram from dm and encodes it to w as a
+// PNG.
+func generatePNG(ctx context.Context, w io.Writer, dm sirdsc.DepthMap, config *GenerateConfig) error {
+	opts := config.options()
+	r := opts.OutputBounds(dm.Bounds(), config.Pattern)
+	if err := checkOutput(r); err != nil {
+		return err
+	}
+	out := image.NewNRGBA(r)
+
+	err := sirdsc.GenerateContext(
+		ctx,
+		out,
+		dm,
+		config.Pattern,
+		opts,
+	)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, out)
 }
 
 var cache sync.Map
 
+// pool is shared between all requests so that generating images
+// doesn't start new goroutines for every request.
+var pool *sirdsc.Pool
+
 type cacheEntry struct {
 	url    string
 	img    Image
@@ -133,26 +284,72 @@ type StillImage struct {
 }
 
 func (img StillImage) Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error {
-	out := image.NewNRGBA(image.Rect(
-		img.Bounds().Min.X,
-		img.Bounds().Min.Y,
-		img.Bounds().Max.X+config.PartSize,
-		img.Bounds().Max.Y,
-	))
-
-	sirdsc.Generate(
-		out,
-		sirdsc.ImageDepthMap{
-			Image:   img,
-			Max:     config.MaxDepth,
-			Flat:    config.Flat,
-			Inverse: config.Inverse,
-		},
-		config.Pattern,
-		config.PartSize,
-	)
+	return generatePNG(ctx, w, config.depthMap(img, img.Bounds()), config)
+}
 
-	return png.Encode(w, out)
+// TextSource is text to generate a stereogram of.
+type TextSource string
+
+func (text TextSource) Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error {
+	if err := checkMax("text length", int64(len(text)), maxTextLength); err != nil {
+		return err
+	}
+	if err := checkMax("number of lines of text", int64(strings.Count(string(text), "\n")+1), maxTextLines); err != nil {
+		return err
+	}
+
+	opts := sirdsc.TextOptions{
+		Font:        config.Font,
+		Size:        config.TextSize,
+		Align:       config.Align,
+		LineSpacing: config.LineSpacing,
+		Depth:       float64(config.MaxDepth),
+		Bevel:       config.Bevel,
+		Round:       config.Round,
+		Padding:     int(config.TextSize / 2),
+	}
+
+	// The text is measured first so that text that is too large is
+	// rejected before the memory for rendering it is allocated.
+	r, err := sirdsc.TextBounds(string(text), &opts)
+	if err != nil {
+		return fmt.Errorf("measure text: %w", err)
+	}
+	if (r.Dx() > maxSize) || (r.Dy() > maxSize) {
+		return fmt.Errorf("%w: rendered text of %vx%v is larger than %vx%v", errBadQuery, r.Dx(), r.Dy(), maxSize, maxSize)
+	}
+
+	dm, err := sirdsc.NewTextDepthMap(string(text), &opts)
+	if err != nil {
+		return fmt.Errorf("render text: %w", err)
+	}
+
+	return generatePNG(ctx, w, config.resample(dm), config)
+}
+
+// ExprSource is an expression to generate a stereogram of the depths
+// of. See sirdsc.ExprDepthMap for details.
+type ExprSource string
+
+func (expr ExprSource) Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error {
+	// Expressions don't have a size of their own, so the configured
+	// size is used directly instead of resampling to it.
+	size := image.Pt(config.Width, config.Height)
+	if size.X <= 0 {
+		size.X = 800
+	}
+	if size.Y <= 0 {
+		size.Y = 600
+	}
+
+	dm, err := sirdsc.NewExprDepthMap(string(expr), image.Rectangle{Max: size})
+	if err != nil {
+		return fmt.Errorf("parse expression: %w", err)
+	}
+	dm.Seed = config.Seed
+	dm.T = config.T
+
+	return generatePNG(ctx, w, dm, config)
 }
 
 type GIFImage struct {
@@ -166,33 +363,27 @@ func (img GIFImage) copy() *gif.GIF {
 }
 
 func (img GIFImage) Generate(ctx context.Context, w io.Writer, config *GenerateConfig) error {
+	opts := config.options()
 	newGIF := img.copy()
-	newGIF.Config.Width += config.PartSize
+	canvas := image.Rect(0, 0, newGIF.Config.Width, newGIF.Config.Height)
 
-	eg, ctx := errgroup.WithContext(ctx)
+	// The depth maps of the frames are prepared concurrently, and their
+	// sizes are checked before any of them are generated.
+	dms := make([]sirdsc.DepthMap, len(img.Image))
+	var pixels atomic.Int64
+	var eg errgroup.Group
 	for i := range img.Image {
 		eg.Go(func() error {
-			out := image.NewPaletted(image.Rect(
-				img.Image[i].Bounds().Min.X,
-				img.Image[i].Bounds().Min.Y,
-				img.Image[i].Bounds().Max.X+config.PartSize,
-				img.Image[i].Bounds().Max.Y,
-			), palette.Plan9)
-
-			sirdsc.Generate(
-				out,
-				sirdsc.ImageDepthMap{
-					Image:   img.Image[i],
-					Max:     config.MaxDepth,
-					Flat:    config.Flat,
-					Inverse: config.Inverse,
-				},
-				config.Pattern,
-				config.PartSize,
-			)
-
-			newGIF.Image[i] = out
-
+			dm := config.depthMap(img.Image[i], canvas)
+			r := opts.OutputBounds(dm.Bounds(), config.Pattern)
+			if err := checkOutput(r); err != nil {
+				return err
+			}
+			if pixels.Add(int64(r.Dx())*int64(r.Dy())) > maxAnimationPixels {
+				return fmt.Errorf("%w: animation is larger than %v pixels", errBadQuery, maxAnimationPixels)
+			}
+
+			dms[i] = dm
 			return nil
 		})
 	}
@@ -202,6 +393,19 @@ func (img GIFImage) Generate(ctx context.Context, w io.Writer, config *GenerateC
 		return err
 	}
 
+	newGIF.Image, err = sirdsc.GenerateFrames(ctx, dms, config.Pattern, opts)
+	if err != nil {
+		return err
+	}
+
+	// Resampled frames cover the whole canvas, so they all have the
+	// same bounds.
+	newGIF.Config.Width = opts.OutputBounds(canvas, config.Pattern).Dx()
+	if (config.Width > 0) || (config.Height > 0) {
+		b := newGIF.Image[0].Bounds()
+		newGIF.Config.Width, newGIF.Config.Height = b.Dx(), b.Dy()
+	}
+
 	return gif.EncodeAll(w, newGIF)
 }
 
@@ -10,16 +10,41 @@ export function App() {
       <div className="flex flex-col bg-base-300 m-4 p-4 pt-25 transform-[translate(0px,-100px)] rounded-lg shadow-lg">
         <div className="flex flex-row justify-end mx-0 my-2">
           <Input label="Depth Map" {...inputs.text("src")} />
+          <Input label="Text" {...inputs.text("text")} />
+          <Input label="Expression" {...inputs.text("expr")} />
+          <Input label="Font" {...inputs.text("font", "basic")} />
+          <Input label="Text Size" {...inputs.range("textsize", 8, 300, 96)} />
+          <Input label="Align" {...inputs.text("align", "left")} />
+          <Input label="Line Spacing" {...inputs.number("linespacing", 1)} />
+          <Input label="Bevel" {...inputs.range("bevel", 0, 30, 0)} />
+          <Input label="Round" {...inputs.checkbox("round")} />
           <Input label="Pattern" {...inputs.text("pat")} />
           <Input label="Seed" {...inputs.number("seed")} />
           <Input label="Part Size" {...inputs.range("partsize", 0, 500, 100)} />
+          <Input label="Width" {...inputs.number("width")} />
+          <Input label="Height" {...inputs.number("height")} />
           <Input label="Max Depth" {...inputs.range("depth", 0, 50, 40)} />
+          <Input label="Oversample" {...inputs.range("oversample", 1, 8, 1)} />
           <Input
             label="Symmetric Random Generation"
             {...inputs.checkbox("sym")}
           />
           <Input label="Inverse" {...inputs.checkbox("inverse")} />
           <Input label="Flat" {...inputs.checkbox("flat")} />
+          <Input label="Signed" {...inputs.checkbox("signed")} />
+          <Input label="Channel" {...inputs.text("channel", "max")} />
+          <Input label="Alpha Mask" {...inputs.checkbox("alphamask")} />
+          <Input label="Auto Levels" {...inputs.checkbox("autolevels")} />
+          <Input label="Equalize" {...inputs.checkbox("equalize")} />
+          <Input label="Curve" {...inputs.text("curve")} />
+          <Input label="Gamma" {...inputs.number("gamma", 1)} />
+          <Input label="Posterize" {...inputs.range("posterize", 0, 16, 0)} />
+          <Input
+            label="Hidden Surface Removal"
+            {...inputs.checkbox("hsr")}
+          />
+          <Input label="Cross-Eyed" {...inputs.checkbox("cross")} />
+          <Input label="Propagate From Center" {...inputs.checkbox("center")} />
         </div>
       </div>
 
@@ -6,13 +6,35 @@ export type DisplayProps = {
 
 export type Params = {
   src: string;
+  text: string;
+  expr: string;
+  font: string;
+  textsize: number;
+  align: string;
+  linespacing: number;
+  bevel: number;
+  round: boolean;
   pat: string;
   seed: number;
   partsize: number;
+  width: number;
+  height: number;
   depth: number;
+  oversample: number;
   sym: boolean;
   inverse: boolean;
   flat: boolean;
+  signed: boolean;
+  channel: string;
+  alphamask: boolean;
+  autolevels: boolean;
+  equalize: boolean;
+  curve: string;
+  gamma: number;
+  posterize: number;
+  hsr: boolean;
+  cross: boolean;
+  center: boolean;
 };
 
 export function Display({ params }: DisplayProps) {
@@ -26,7 +48,7 @@ export function Display({ params }: DisplayProps) {
   );
   const src = useMemo(() => `/generate?${query}`, [query]);
 
-  return params.src ? (
+  return params.src || params.text || params.expr ? (
     <img className="flex-1 m-4" alt="Display" src={src} />
   ) : null;
 }
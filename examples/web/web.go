@@ -3,14 +3,21 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 
+	"github.com/DeedleFake/sirdsc"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -19,6 +26,51 @@ import (
 //go:embed dist
 var distFS embed.FS
 
+// These are the largest values allowed for the query parameters that
+// the memory and time that generating an image takes grow with, so
+// that a single request can't exhaust the server's resources.
+const (
+	maxOversample = 8
+	maxSize       = 4096
+	maxTextSize   = 1000
+	maxTextLength = 1000
+	maxTextLines  = 20
+	maxSpacing    = 10
+	maxDPI        = 1200
+	maxEyeSep     = 5
+	maxPartSize   = 2048
+
+	// maxPixels is the largest number of pixels in a generated image,
+	// and maxAnimationPixels is the largest number of pixels in all of
+	// the frames of a generated animation.
+	maxPixels          = (maxSize + maxPartSize) * maxSize
+	maxAnimationPixels = 4 * maxPixels
+)
+
+// errBadQuery is returned when the query of a request is invalid in a
+// way that should be reported to the client as a bad request.
+var errBadQuery = errors.New("bad query")
+
+// checkMax returns an error if the query parameter name's value, v, is
+// larger than max.
+func checkMax[T int64 | float64](name string, v, max T) error {
+	if v > max {
+		return fmt.Errorf("%w: %v is larger than %v", errBadQuery, name, max)
+	}
+	return nil
+}
+
+// parseFinite parses the query parameter name as a floating-point
+// number. As with the other parameters, a missing or malformed value is
+// zero, but infinities and NaN are rejected.
+func parseFinite(q url.Values, name string) (float64, error) {
+	v, _ := strconv.ParseFloat(q.Get(name), 64)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%w: %v is not finite", errBadQuery, name)
+	}
+	return v, nil
+}
+
 func configFromQuery(ctx context.Context, q url.Values) (*GenerateConfig, error) {
 	seed, _ := strconv.ParseUint(q.Get("seed"), 10, 0)
 	pat, err := GetPattern(ctx, seed, q.Get("sym") == "true", q.Get("pat"))
@@ -30,21 +82,232 @@ func configFromQuery(ctx context.Context, q url.Values) (*GenerateConfig, error)
 	if partSize <= 0 {
 		partSize = 100
 	}
+	if err := checkMax("partsize", partSize, maxPartSize); err != nil {
+		return nil, err
+	}
 
 	maxDepth, _ := strconv.ParseInt(q.Get("depth"), 10, 0)
 	if maxDepth <= 0 {
 		maxDepth = 40
 	}
 
+	var geometry *sirdsc.ViewingGeometry
+	dpi, err := parseFinite(q, "dpi")
+	if err != nil {
+		return nil, err
+	}
+	if dpi > 0 {
+		geometry = &sirdsc.ViewingGeometry{DPI: dpi}
+		fields := []struct {
+			name string
+			v    *float64
+		}{
+			{"distance", &geometry.Distance},
+			{"eyesep", &geometry.EyeSeparation},
+			{"farplane", &geometry.FarPlane},
+			{"mu", &geometry.Mu},
+		}
+		for _, f := range fields {
+			*f.v, err = parseFinite(q, f.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := checkMax("dpi", dpi, maxDPI); err != nil {
+			return nil, err
+		}
+		if err := checkMax("eyesep", geometry.EyeSeparation, maxEyeSep); err != nil {
+			return nil, err
+		}
+		if geometry.Mu >= 1 {
+			return nil, fmt.Errorf("%w: mu must be less than 1", errBadQuery)
+		}
+
+		// The separation of the far plane becomes the part size, which
+		// is added to the width of the output.
+		sep := max(geometry.Separation(0), geometry.Separation(1))
+		if err := checkMax("part size", sep, maxPartSize); err != nil {
+			return nil, err
+		}
+	}
+
+	oversample, _ := strconv.ParseInt(q.Get("oversample"), 10, 0)
+	if err := checkMax("oversample", oversample, maxOversample); err != nil {
+		return nil, err
+	}
+
+	var channel sirdsc.Channel
+	switch q.Get("channel") {
+	case "", "max":
+		channel = sirdsc.ChannelMax
+	case "luma":
+		channel = sirdsc.ChannelLuma
+	case "red", "r":
+		channel = sirdsc.ChannelRed
+	case "green", "g":
+		channel = sirdsc.ChannelGreen
+	case "blue", "b":
+		channel = sirdsc.ChannelBlue
+	case "alpha", "a":
+		channel = sirdsc.ChannelAlpha
+	default:
+		return nil, fmt.Errorf("unknown channel: %q", q.Get("channel"))
+	}
+
+	curve, err := sirdsc.ParseCurve(q.Get("curve"))
+	if err != nil {
+		return nil, fmt.Errorf("parse curve: %w", err)
+	}
+	gamma, _ := strconv.ParseFloat(q.Get("gamma"), 64)
+	posterize, _ := strconv.ParseInt(q.Get("posterize"), 10, 0)
+
+	width, _ := strconv.ParseInt(q.Get("width"), 10, 0)
+	height, _ := strconv.ParseInt(q.Get("height"), 10, 0)
+	if err := checkMax("width", width, maxSize); err != nil {
+		return nil, err
+	}
+	if err := checkMax("height", height, maxSize); err != nil {
+		return nil, err
+	}
+
+	var filter sirdsc.Filter
+	switch q.Get("filter") {
+	case "", "bilinear":
+		filter = sirdsc.Bilinear
+	case "nearest":
+		filter = sirdsc.NearestNeighbor
+	case "bicubic":
+		filter = sirdsc.Bicubic
+	default:
+		return nil, fmt.Errorf("unknown filter: %q", q.Get("filter"))
+	}
+
+	var resample sirdsc.ResampleMode
+	switch q.Get("fit") {
+	case "", "fit":
+		resample = sirdsc.Fit
+	case "fill":
+		resample = sirdsc.Fill
+	case "stretch":
+		resample = sirdsc.Stretch
+	default:
+		return nil, fmt.Errorf("unknown fit: %q", q.Get("fit"))
+	}
+
+	var framing sirdsc.Framing
+	switch q.Get("framing") {
+	case "", "extra":
+		framing = sirdsc.ExtraStrip
+	case "crop":
+		framing = sirdsc.Cropped
+	case "center":
+		framing = sirdsc.Centered
+	default:
+		return nil, fmt.Errorf("unknown framing: %q", q.Get("framing"))
+	}
+
+	f, err := getFont(q.Get("font"))
+	if err != nil {
+		return nil, fmt.Errorf("get font: %w", err)
+	}
+
+	textSize, err := parseFinite(q, "textsize")
+	if err != nil {
+		return nil, err
+	}
+	if textSize <= 0 {
+		textSize = 96
+	}
+	if err := checkMax("textsize", textSize, maxTextSize); err != nil {
+		return nil, err
+	}
+
+	var align sirdsc.TextAlign
+	switch q.Get("align") {
+	case "", "left":
+		align = sirdsc.AlignLeft
+	case "center":
+		align = sirdsc.AlignCenter
+	case "right":
+		align = sirdsc.AlignRight
+	default:
+		return nil, fmt.Errorf("unknown alignment: %q", q.Get("align"))
+	}
+
+	// A line spacing of zero is the default of 1, and negative ones
+	// would stack the lines upwards, which isn't useful.
+	lineSpacing, err := parseFinite(q, "linespacing")
+	if err != nil {
+		return nil, err
+	}
+	if lineSpacing < 0 {
+		return nil, fmt.Errorf("%w: linespacing is negative", errBadQuery)
+	}
+	if err := checkMax("linespacing", lineSpacing, maxSpacing); err != nil {
+		return nil, err
+	}
+
+	bevel, err := parseFinite(q, "bevel")
+	if err != nil {
+		return nil, err
+	}
+	t, _ := strconv.ParseFloat(q.Get("t"), 64)
+
 	return &GenerateConfig{
-		Pattern:  pat,
-		PartSize: int(partSize),
-		MaxDepth: int(maxDepth),
-		Flat:     q.Get("flat") == "true",
-		Inverse:  q.Get("inverse") == "true",
+		Pattern:     pat,
+		PartSize:    int(partSize),
+		MaxDepth:    int(maxDepth),
+		Flat:        q.Get("flat") == "true",
+		Inverse:     q.Get("inverse") == "true",
+		Signed:      q.Get("signed") == "true",
+		Channel:     channel,
+		AlphaMask:   q.Get("alphamask") == "true",
+		AutoLevels:  q.Get("autolevels") == "true",
+		Equalize:    q.Get("equalize") == "true",
+		Curve:       curve,
+		Gamma:       gamma,
+		Posterize:   int(posterize),
+		Width:       int(width),
+		Height:      int(height),
+		Filter:      filter,
+		Resample:    resample,
+		HSR:         q.Get("hsr") == "true",
+		Cross:       q.Get("cross") == "true",
+		Geometry:    geometry,
+		Center:      q.Get("center") == "true",
+		Framing:     framing,
+		Oversample:  int(oversample),
+		Font:        f,
+		TextSize:    textSize,
+		Align:       align,
+		LineSpacing: lineSpacing,
+		Bevel:       bevel,
+		Round:       q.Get("round") == "true",
+		Seed:        seed,
+		T:           t,
 	}, nil
 }
 
+// getFont returns one of the Go fonts by name. The empty string and
+// "basic" are the built-in bitmap font, which is represented by nil.
+func getFont(name string) (*opentype.Font, error) {
+	var ttf []byte
+	switch name {
+	case "", "basic":
+		return nil, nil
+	case "regular":
+		ttf = goregular.TTF
+	case "bold":
+		ttf = gobold.TTF
+	case "mono":
+		ttf = gomono.TTF
+	default:
+		return nil, fmt.Errorf("unknown font: %q", name)
+	}
+	return opentype.Parse(ttf)
+}
+
 func handleGenerate(rw http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithCancel(req.Context())
 	defer cancel()
@@ -52,13 +315,15 @@ func handleGenerate(rw http.ResponseWriter, req *http.Request) {
 	q := req.URL.Query()
 
 	src := q.Get("src")
-	if src == "" {
-		http.Error(rw, "No source specified.", http.StatusBadRequest)
+	text := q.Get("text")
+	expr := q.Get("expr")
+	if (src == "") && (text == "") && (expr == "") {
+		http.Error(rw, "No source, text, or expression specified.", http.StatusBadRequest)
 		return
 	}
-	slog := slog.With("src", src)
+	slog := slog.With("src", src, "text", text, "expr", expr)
 
-	imgC := make(chan Image, 1)
+	imgC := make(chan Source, 1)
 	configC := make(chan *GenerateConfig, 1)
 
 	eg, ctx := errgroup.WithContext(ctx)
@@ -77,9 +342,18 @@ func handleGenerate(rw http.ResponseWriter, req *http.Request) {
 	})
 
 	eg.Go(func() error {
-		img, err := GetImage(ctx, src)
-		if err != nil {
-			return fmt.Errorf("get depth map: %w", err)
+		var img Source
+		switch {
+		case expr != "":
+			img = ExprSource(expr)
+		case text != "":
+			img = TextSource(text)
+		default:
+			var err error
+			img, err = GetImage(ctx, src)
+			if err != nil {
+				return fmt.Errorf("get depth map: %w", err)
+			}
 		}
 
 		select {
@@ -91,7 +365,7 @@ func handleGenerate(rw http.ResponseWriter, req *http.Request) {
 	})
 
 	eg.Go(func() error {
-		var img Image
+		var img Source
 		var config *GenerateConfig
 		for img == nil || config == nil {
 			select {
@@ -112,7 +386,11 @@ func handleGenerate(rw http.ResponseWriter, req *http.Request) {
 
 	err := eg.Wait()
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errBadQuery) {
+			status = http.StatusBadRequest
+		}
+		http.Error(rw, err.Error(), status)
 		slog.Error("generate failed", "err", err)
 	}
 }
@@ -131,8 +409,12 @@ func logHandler(h http.Handler) http.Handler {
 
 func main() {
 	addr := flag.String("addr", ":8080", "The address to listen on.")
+	workers := flag.Int("workers", 0, "The number of workers to generate images with. Defaults to GOMAXPROCS.")
 	flag.Parse()
 
+	pool = sirdsc.NewPool(*workers)
+	defer pool.Close()
+
 	http.Handle("GET /generate", logHandler(http.HandlerFunc(handleGenerate)))
 	http.Handle("GET /dist/", logHandler(http.FileServerFS(distFS)))
 	http.Handle("GET /", logHandler(http.HandlerFunc(handleIndex)))
@@ -0,0 +1,625 @@
+package sirdsc
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExprDepthMap is a depth map whose depths are calculated by a
+// mathematical expression, such as
+//
+//	20*sin(hypot(x-w/2, y-h/2)/15)
+//
+// Expressions are made of numbers, the operators +, -, *, /, % (the
+// floating-point remainder), and ^ (exponentiation), parentheses,
+// variables, and calls of functions. The variables are
+//
+//	x, y  the coordinates of the pixel
+//	w, h  the width and height of the depth map's bounds
+//	t     the value of T, such as the time of a frame of an animation
+//	pi, e the mathematical constants
+//
+// and the functions are
+//
+//	sin, cos, tan, asin, acos, atan, atan2(y, x)
+//	sinh, cosh, tanh
+//	sqrt, cbrt, exp, log, log2, log10, pow(x, y)
+//	abs, sign, floor, ceil, round, trunc, mod(x, y)
+//	min(a, b, ...), max(a, b, ...), clamp(v, lo, hi), hypot(x, y)
+//	noise(x, y), noise(x, y, z)
+//
+// noise returns smooth noise in the range [-1, 1) that changes over a
+// distance of about 1 and is determined by Seed. Its third argument
+// can be used to animate it smoothly, for example by passing t.
+//
+// Expressions can't loop or do anything other than calculate a number.
+// They are limited to 1024 characters, 256 numbers, variables,
+// operators, and calls, and 32 levels of nesting, so the time that it
+// takes to evaluate each pixel is bounded. The time that it takes to
+// evaluate the whole depth map still grows with its size, so callers
+// that accept expressions from untrusted sources should limit that,
+// too. Depths that aren't finite, such as
+// from dividing by zero, are zero.
+//
+// ExprDepthMaps should be created with NewExprDepthMap. The zero value
+// has no expression, and all of its depths are zero.
+type ExprDepthMap struct {
+	// Rect is the depth map's bounds.
+	Rect image.Rectangle
+
+	// T is the value of the variable t.
+	T float64
+
+	// Seed is the seed of noise. Expressions evaluated with the same
+	// seed always return the same noise.
+	Seed uint64
+
+	eval exprFunc
+}
+
+const (
+	// maxExprLength is the longest expression, in bytes, that can be
+	// compiled.
+	maxExprLength = 1024
+
+	// maxExprNodes is the largest number of numbers, variables,
+	// operators, and calls that an expression can have.
+	maxExprNodes = 256
+
+	// maxExprDepth is the deepest that parentheses, calls, signs, and
+	// exponents can be nested in an expression.
+	maxExprDepth = 32
+)
+
+// NewExprDepthMap compiles expr into an ExprDepthMap with the bounds
+// r. The error, if any, points at the column of expr that caused it.
+func NewExprDepthMap(expr string, r image.Rectangle) (*ExprDepthMap, error) {
+	if len(expr) > maxExprLength {
+		return nil, fmt.Errorf("column %v: expression is longer than %v characters", maxExprLength+1, maxExprLength)
+	}
+
+	p := exprParser{lex: exprLexer{src: expr}}
+	p.next()
+	eval, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExprDepthMap{
+		Rect: r,
+		eval: eval,
+	}, nil
+}
+
+// Bounds returns the bounds of the depth map.
+func (dm *ExprDepthMap) Bounds() image.Rectangle {
+	return dm.Rect
+}
+
+func (dm *ExprDepthMap) At(x, y int) int { // nolint
+	return int(dm.AtF(x, y))
+}
+
+// AtF returns the depth at (x, y) without truncating it to an integer.
+func (dm *ExprDepthMap) AtF(x, y int) float64 {
+	env := dm.env(y)
+	env.x = float64(x)
+	return env.depth(dm.eval)
+}
+
+func (dm *ExprDepthMap) depthRow(dst []int, x, y int) {
+	exprDepthRow(dm, dst, x, y, func(v float64) int { return int(v) })
+}
+
+func (dm *ExprDepthMap) depthRowF(dst []float64, x, y int) {
+	exprDepthRow(dm, dst, x, y, func(v float64) float64 { return v })
+}
+
+// exprDepthRow fills dst with the depths of dm from (x, y) to
+// (x+len(dst)-1, y), converted with conv.
+func exprDepthRow[T int | float64](dm *ExprDepthMap, dst []T, x, y int, conv func(float64) T) {
+	env := dm.env(y)
+	for i := range dst {
+		env.x = float64(x + i)
+		dst[i] = conv(env.depth(dm.eval))
+	}
+}
+
+// env returns the variables for evaluating the expression of dm for
+// the row y.
+func (dm *ExprDepthMap) env(y int) *exprEnv {
+	return &exprEnv{
+		y:    float64(y),
+		w:    float64(dm.Rect.Dx()),
+		h:    float64(dm.Rect.Dy()),
+		t:    dm.T,
+		seed: dm.Seed,
+	}
+}
+
+// exprEnv holds the values of the variables of an expression.
+type exprEnv struct {
+	x, y, w, h, t float64
+	seed          uint64
+}
+
+// depth evaluates eval and returns the result, or zero if it isn't
+// finite or eval is nil.
+func (env *exprEnv) depth(eval exprFunc) float64 {
+	if eval == nil {
+		return 0
+	}
+
+	v := eval(env)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// exprFunc is a compiled expression.
+type exprFunc func(env *exprEnv) float64
+
+// exprVars are the variables that can be used in an expression.
+var exprVars = map[string]exprFunc{
+	"x":  func(env *exprEnv) float64 { return env.x },
+	"y":  func(env *exprEnv) float64 { return env.y },
+	"w":  func(env *exprEnv) float64 { return env.w },
+	"h":  func(env *exprEnv) float64 { return env.h },
+	"t":  func(env *exprEnv) float64 { return env.t },
+	"pi": func(env *exprEnv) float64 { return math.Pi },
+	"e":  func(env *exprEnv) float64 { return math.E },
+}
+
+// exprFuncs1 and exprFuncs2 are the functions of one and two arguments
+// that can be called in an expression.
+var (
+	exprFuncs1 = map[string]func(float64) float64{
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"asin":  math.Asin,
+		"acos":  math.Acos,
+		"atan":  math.Atan,
+		"sinh":  math.Sinh,
+		"cosh":  math.Cosh,
+		"tanh":  math.Tanh,
+		"sqrt":  math.Sqrt,
+		"cbrt":  math.Cbrt,
+		"exp":   math.Exp,
+		"log":   math.Log,
+		"log2":  math.Log2,
+		"log10": math.Log10,
+		"abs":   math.Abs,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"trunc": math.Trunc,
+		"sign": func(v float64) float64 {
+			switch {
+			case v > 0:
+				return 1
+			case v < 0:
+				return -1
+			default:
+				return 0
+			}
+		},
+	}
+
+	exprFuncs2 = map[string]func(float64, float64) float64{
+		"atan2": math.Atan2,
+		"pow":   math.Pow,
+		"mod":   math.Mod,
+		"hypot": math.Hypot,
+	}
+)
+
+// exprNoise returns smooth noise at (x, y, z), interpolating between
+// layers of the value noise used by NewTerrainDepthMap.
+func exprNoise(seed uint64, x, y, z float64) float64 {
+	z0 := math.Floor(z)
+	layer := int(z0)
+	v0 := valueNoise(seed, layer, x, y)
+	if z == z0 {
+		return v0
+	}
+	v1 := valueNoise(seed, layer+1, x, y)
+	return v0 + (v1-v0)*smootherstep(z-z0)
+}
+
+// exprToken is the kind of a token of an expression.
+type exprToken int
+
+const (
+	exprEOF exprToken = iota
+	exprNumber
+	exprIdent
+	exprOp
+)
+
+// exprLexer splits an expression into tokens.
+type exprLexer struct {
+	src string
+	pos int
+}
+
+// next returns the next token, its text, and the position of its
+// first byte.
+func (lex *exprLexer) next() (tok exprToken, text string, pos int, err error) {
+	for (lex.pos < len(lex.src)) && unicode.IsSpace(rune(lex.src[lex.pos])) {
+		lex.pos++
+	}
+	start := lex.pos
+	if lex.pos >= len(lex.src) {
+		return exprEOF, "", start, nil
+	}
+
+	c := lex.src[lex.pos]
+	switch {
+	case isDigit(c) || (c == '.'):
+		for (lex.pos < len(lex.src)) && (isDigit(lex.src[lex.pos]) || (lex.src[lex.pos] == '.')) {
+			lex.pos++
+		}
+		if (lex.pos < len(lex.src)) && ((lex.src[lex.pos] == 'e') || (lex.src[lex.pos] == 'E')) {
+			end := lex.pos + 1
+			if (end < len(lex.src)) && ((lex.src[end] == '+') || (lex.src[end] == '-')) {
+				end++
+			}
+			if (end < len(lex.src)) && isDigit(lex.src[end]) {
+				for lex.pos = end; (lex.pos < len(lex.src)) && isDigit(lex.src[lex.pos]); lex.pos++ {
+				}
+			}
+		}
+		return exprNumber, lex.src[start:lex.pos], start, nil
+
+	case isLetter(c):
+		for (lex.pos < len(lex.src)) && (isLetter(lex.src[lex.pos]) || isDigit(lex.src[lex.pos])) {
+			lex.pos++
+		}
+		return exprIdent, lex.src[start:lex.pos], start, nil
+
+	case strings.IndexByte("+-*/%^(),", c) >= 0:
+		lex.pos++
+		return exprOp, lex.src[start:lex.pos], start, nil
+
+	default:
+		return exprEOF, "", start, fmt.Errorf("column %v: unexpected %q", start+1, c)
+	}
+}
+
+func isDigit(c byte) bool {
+	return (c >= '0') && (c <= '9')
+}
+
+func isLetter(c byte) bool {
+	return ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || (c == '_')
+}
+
+// exprParser compiles an expression with recursive descent.
+type exprParser struct {
+	lex exprLexer
+
+	// tok, text, and pos are the current token.
+	tok  exprToken
+	text string
+	pos  int
+	err  error
+
+	// nodes is the number of nodes that have been compiled, and depth
+	// is how deeply nested the current one is.
+	nodes int
+	depth int
+}
+
+// next advances to the next token.
+func (p *exprParser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.text, p.pos, p.err = p.lex.next()
+}
+
+// is returns true if the current token is the operator op.
+func (p *exprParser) is(op string) bool {
+	return (p.tok == exprOp) && (p.text == op)
+}
+
+// errorf returns an error at the position of the current token.
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("column %v: %v", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// node counts a compiled node, and returns an error at the current
+// token if there are too many.
+func (p *exprParser) node() error {
+	p.nodes++
+	if p.nodes > maxExprNodes {
+		return p.errorf("expression has more than %v operations", maxExprNodes)
+	}
+	return nil
+}
+
+// unexpected returns an error for an unexpected current token, or the
+// error that the lexer returned instead of it.
+func (p *exprParser) unexpected() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok == exprEOF {
+		return p.errorf("unexpected end of expression")
+	}
+	return p.errorf("unexpected %q", p.text)
+}
+
+// parse compiles the whole expression.
+func (p *exprParser) parse() (exprFunc, error) {
+	eval, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok != exprEOF {
+		return nil, p.unexpected()
+	}
+	return eval, nil
+}
+
+// sum parses terms separated by + and -.
+func (p *exprParser) sum() (exprFunc, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.text
+		if err := p.node(); err != nil {
+			return nil, err
+		}
+		p.next()
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		switch op {
+		case "+":
+			left = func(env *exprEnv) float64 { return l(env) + right(env) }
+		case "-":
+			left = func(env *exprEnv) float64 { return l(env) - right(env) }
+		}
+	}
+	return left, nil
+}
+
+// product parses factors separated by *, /, and %.
+func (p *exprParser) product() (exprFunc, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.text
+		if err := p.node(); err != nil {
+			return nil, err
+		}
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		switch op {
+		case "*":
+			left = func(env *exprEnv) float64 { return l(env) * right(env) }
+		case "/":
+			left = func(env *exprEnv) float64 { return l(env) / right(env) }
+		case "%":
+			left = func(env *exprEnv) float64 { return math.Mod(l(env), right(env)) }
+		}
+	}
+	return left, nil
+}
+
+// unary parses a factor with any number of signs in front of it. Every
+// nested expression is parsed through it, so it tracks how deeply they
+// are nested.
+func (p *exprParser) unary() (exprFunc, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, p.errorf("expression is nested more than %v deep", maxExprDepth)
+	}
+
+	switch {
+	case p.is("-"):
+		if err := p.node(); err != nil {
+			return nil, err
+		}
+		p.next()
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) float64 { return -v(env) }, nil
+	case p.is("+"):
+		p.next()
+		return p.unary()
+	default:
+		return p.power()
+	}
+}
+
+// power parses an exponentiation. ^ is right-associative and binds
+// more tightly than a sign on its left, so -2^2 is -4.
+func (p *exprParser) power() (exprFunc, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("^") {
+		return base, nil
+	}
+
+	if err := p.node(); err != nil {
+		return nil, err
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(env *exprEnv) float64 { return math.Pow(base(env), exp(env)) }, nil
+}
+
+// primary parses a number, a variable, a call, or an expression in
+// parentheses.
+func (p *exprParser) primary() (exprFunc, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if (p.tok == exprNumber) || (p.tok == exprIdent) {
+		if err := p.node(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.tok == exprNumber:
+		v, err := strconv.ParseFloat(p.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.text)
+		}
+		p.next()
+		return func(*exprEnv) float64 { return v }, nil
+
+	case p.tok == exprIdent:
+		name, pos := p.text, p.pos
+		p.next()
+		if p.is("(") {
+			return p.call(name, pos)
+		}
+		v, ok := exprVars[name]
+		if !ok {
+			return nil, fmt.Errorf("column %v: unknown variable %q", pos+1, name)
+		}
+		return v, nil
+
+	case p.is("("):
+		p.next()
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.is(")") {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return v, nil
+
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// call parses the arguments of a call of the function name, which
+// starts at pos, and compiles the call.
+func (p *exprParser) call(name string, pos int) (exprFunc, error) {
+	p.next()
+	var args []exprFunc
+	if !p.is(")") {
+		for {
+			arg, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if !p.is(")") {
+		return nil, p.unexpected()
+	}
+	p.next()
+
+	arity := func(lo, hi int) error {
+		if (len(args) < lo) || (len(args) > hi) {
+			want := strconv.Itoa(lo)
+			switch {
+			case hi > lo+1:
+				want = fmt.Sprintf("at least %v", lo)
+			case hi > lo:
+				want = fmt.Sprintf("%v or %v", lo, hi)
+			}
+			return fmt.Errorf("column %v: %v takes %v arguments, not %v", pos+1, name, want, len(args))
+		}
+		return nil
+	}
+
+	if f, ok := exprFuncs1[name]; ok {
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		a := args[0]
+		return func(env *exprEnv) float64 { return f(a(env)) }, nil
+	}
+	if f, ok := exprFuncs2[name]; ok {
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		a, b := args[0], args[1]
+		return func(env *exprEnv) float64 { return f(a(env), b(env)) }, nil
+	}
+
+	switch name {
+	case "min", "max":
+		if err := arity(2, math.MaxInt); err != nil {
+			return nil, err
+		}
+		pick := math.Min
+		if name == "max" {
+			pick = math.Max
+		}
+		return func(env *exprEnv) float64 {
+			v := args[0](env)
+			for _, arg := range args[1:] {
+				v = pick(v, arg(env))
+			}
+			return v
+		}, nil
+
+	case "clamp":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		v, lo, hi := args[0], args[1], args[2]
+		return func(env *exprEnv) float64 {
+			return math.Min(math.Max(v(env), lo(env)), hi(env))
+		}, nil
+
+	case "noise":
+		if err := arity(2, 3); err != nil {
+			return nil, err
+		}
+		x, y := args[0], args[1]
+		if len(args) == 2 {
+			return func(env *exprEnv) float64 { return exprNoise(env.seed, x(env), y(env), 0) }, nil
+		}
+		z := args[2]
+		return func(env *exprEnv) float64 { return exprNoise(env.seed, x(env), y(env), z(env)) }, nil
+
+	default:
+		return nil, fmt.Errorf("column %v: unknown function %q", pos+1, name)
+	}
+}
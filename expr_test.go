@@ -0,0 +1,127 @@
+package sirdsc_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestExprDepthMap(t *testing.T) {
+	r := image.Rect(0, 0, 40, 20)
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2*3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2^3^2", 512},
+		{"-2^2", -4},
+		{"2^-1", 0.5},
+		{"7 % 4 - -1", 4},
+		{"1e1 + .5 + 2.5E-1", 10.75},
+		{"x + 10*y", 53},
+		{"w - h", 20},
+		{"t", 1.5},
+		{"pi - e", math.Pi - math.E},
+		{"hypot(x + 1, y - 2)", 5},
+		{"min(4, x, 9) + max(1, 2)", 5},
+		{"clamp(100, 0, 20)", 20},
+		{"20*sin(hypot(x-w/2,y-h/2)/15)", 20 * math.Sin(math.Hypot(3-20, 5-10)/15)},
+		{"atan2(1, 1)*4", math.Pi},
+		{"abs(-3) + sign(-2) + floor(1.5) + round(1.5)", 5},
+		{"1/0", 0},
+		{"sqrt(-1)", 0},
+	}
+	for _, test := range tests {
+		dm, err := sirdsc.NewExprDepthMap(test.expr, r)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		dm.T = 1.5
+		if got := dm.AtF(3, 5); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%q: %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestExprDepthMapErrors(t *testing.T) {
+	tests := map[string]string{
+		"":            "column 1: unexpected end of expression",
+		"1 +":         "column 4: unexpected end of expression",
+		"(1":          "column 3: unexpected end of expression",
+		"1 2":         `column 3: unexpected "2"`,
+		"2 * $":       `column 5: unexpected '$'`,
+		"z":           `column 1: unknown variable "z"`,
+		"1 + f(x)":    `column 5: unknown function "f"`,
+		"sin(1, 2)":   "column 1: sin takes 1 arguments, not 2",
+		"max(1)":      "column 1: max takes at least 2 arguments, not 1",
+		"noise()":     "column 1: noise takes 2 or 3 arguments, not 0",
+		"1.2.3":       `column 1: invalid number "1.2.3"`,
+		"hypot(x,,y)": `column 9: unexpected ","`,
+
+		strings.Repeat("-", 600000) + "x":    "column 1025: expression is longer than 1024 characters",
+		strings.Repeat("-", 40) + "x":        "column 33: expression is nested more than 32 deep",
+		strings.Repeat("(", 40) + "x":        "column 33: expression is nested more than 32 deep",
+		strings.Repeat("x+", 200) + "x":      "column 257: expression has more than 256 operations",
+		"x" + strings.Repeat("*sin(x)", 130): "column 597: expression has more than 256 operations",
+	}
+	for expr, want := range tests {
+		_, err := sirdsc.NewExprDepthMap(expr, image.Rect(0, 0, 1, 1))
+		if (err == nil) || (err.Error() != want) {
+			t.Errorf("%q: %v, want %v", expr, err, want)
+		}
+	}
+}
+
+func TestExprDepthMapNoise(t *testing.T) {
+	r := image.Rect(0, 0, 64, 32)
+	gen := func(seed uint64, z float64) *sirdsc.Depth {
+		dm, err := sirdsc.NewExprDepthMap("noise(x/8, y/8, t)", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dm.Seed, dm.T = seed, z
+		return sirdsc.Materialize(dm)
+	}
+
+	d := gen(1, 0.25)
+	for i, v := range d.Pix {
+		if (v < -1) || (v >= 1) {
+			t.Fatalf("%v: noise %v out of range", i, v)
+		}
+	}
+	if !reflect.DeepEqual(gen(1, 0.25), d) {
+		t.Error("same seed generated different noise")
+	}
+	if reflect.DeepEqual(gen(2, 0.25), d) {
+		t.Error("different seeds generated the same noise")
+	}
+
+	// The noise changes smoothly along the third dimension.
+	next := gen(1, 0.3)
+	for i := range d.Pix {
+		if math.Abs(float64(next.Pix[i]-d.Pix[i])) > 0.2 {
+			t.Fatalf("%v: noise jumped from %v to %v", i, d.Pix[i], next.Pix[i])
+		}
+	}
+}
+
+func TestExprDepthMapZero(t *testing.T) {
+	dm := &sirdsc.ExprDepthMap{Rect: image.Rect(0, 0, 60, 10)}
+	if (dm.At(3, 5) != 0) || (dm.AtF(3, 5) != 0) {
+		t.Fatalf("zero value has depth %v", dm.AtF(3, 5))
+	}
+
+	// Generating a stereogram reads the depths a row at a time.
+	out := image.NewNRGBA(image.Rect(0, 0, 80, 10))
+	err := sirdsc.GenerateContext(context.Background(), out, dm, sirdsc.RandImage{Seed: 1}, &sirdsc.Options{PartSize: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package sirdsc
+
+const (
+	// DefaultDPI is the display resolution used by ViewingGeometry if
+	// none is specified.
+	DefaultDPI = 96
+
+	// DefaultEyeSeparation is the distance between the viewer's eyes,
+	// in inches, used by ViewingGeometry if none is specified.
+	DefaultEyeSeparation = 2.5
+
+	// DefaultDistance is the distance from the viewer's eyes to the
+	// screen, in inches, used by ViewingGeometry if none is specified.
+	DefaultDistance = 24
+
+	// DefaultMu is the depth of field used by ViewingGeometry if none is
+	// specified.
+	DefaultMu = 1.0 / 3
+)
+
+// ViewingGeometry describes the physical conditions that a stereogram
+// will be viewed in. It is used to convert depths into the separation
+// between the pixels that each of the viewer's eyes sees so that the
+// same depth map produces comfortable results whether it is displayed
+// on a phone or printed as a poster.
+//
+// The model is the one used by Thimbleby, Inglis, and Witten. The
+// viewer's eyes are Distance in front of the screen, and the surface
+// being displayed lies between a far plane that is FarPlane behind the
+// screen and a near plane that is Mu*FarPlane in front of the far
+// plane. A normalized depth of 0 is on the far plane and a normalized
+// depth of 1 is on the near plane.
+//
+// The zero value of any field causes a default to be used.
+type ViewingGeometry struct {
+	// DPI is the resolution of the display in pixels per inch.
+	DPI float64
+
+	// EyeSeparation is the distance between the viewer's eyes in
+	// inches.
+	EyeSeparation float64
+
+	// Distance is the distance from the viewer's eyes to the screen in
+	// inches.
+	Distance float64
+
+	// FarPlane is the distance behind the screen of the far plane in
+	// inches. If it is zero, it is the same as Distance.
+	FarPlane float64
+
+	// Mu is the distance between the far and near planes as a fraction
+	// of FarPlane. It should be less than 1, and is usually about 1/3.
+	Mu float64
+}
+
+func (g ViewingGeometry) withDefaults() ViewingGeometry {
+	if g.DPI <= 0 {
+		g.DPI = DefaultDPI
+	}
+	if g.EyeSeparation <= 0 {
+		g.EyeSeparation = DefaultEyeSeparation
+	}
+	if g.Distance <= 0 {
+		g.Distance = DefaultDistance
+	}
+	if g.FarPlane <= 0 {
+		g.FarPlane = g.Distance
+	}
+	if g.Mu <= 0 {
+		g.Mu = DefaultMu
+	}
+	return g
+}
+
+// Separation returns the distance in pixels between the two points on
+// the screen that the viewer's eyes see a point with the normalized
+// depth z through. Depths less than 0 are behind the far plane and
+// depths greater than 1 are in front of the near plane.
+func (g ViewingGeometry) Separation(z float64) float64 {
+	g = g.withDefaults()
+
+	e := g.EyeSeparation * g.DPI
+	t := g.FarPlane * (1 - g.Mu*z)
+	return e * t / (g.Distance + t)
+}
@@ -0,0 +1,62 @@
+package sirdsc_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestViewingGeometrySeparation(t *testing.T) {
+	tests := []struct {
+		name string
+		g    sirdsc.ViewingGeometry
+		z    float64
+		want float64
+	}{
+		{name: "Far", g: sirdsc.ViewingGeometry{}, z: 0, want: 120},
+		{name: "Near", g: sirdsc.ViewingGeometry{}, z: 1, want: 96},
+		{name: "Print", g: sirdsc.ViewingGeometry{DPI: 300, Distance: 40, FarPlane: 10, Mu: 0.5}, z: 0.5, want: 750 * 7.5 / 47.5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.g.Separation(test.z)
+			if math.Abs(got-test.want) > 1e-9 {
+				t.Fatalf("Separation(%v) == %v, want %v", test.z, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateContextGeometry(t *testing.T) {
+	g := sirdsc.ViewingGeometry{DPI: 20}
+	dm := constDepthMap{rect: image.Rect(0, 0, 200, 10), depth: 20}
+	sep := int(math.Round(g.Separation(0.5)))
+
+	for _, algorithm := range []sirdsc.Algorithm{sirdsc.Copy, sirdsc.HiddenSurface} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			out := image.NewNRGBA(image.Rect(0, 0, 200, 10))
+			err := sirdsc.GenerateContext(context.Background(), out, dm, sirdsc.RandImage{Seed: 1}, &sirdsc.Options{
+				Algorithm: algorithm,
+				Geometry:  &g,
+				MaxDepth:  40,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for y := out.Rect.Min.Y; y < out.Rect.Max.Y; y++ {
+				for x := 100; x+sep < out.Rect.Max.X-sep; x++ {
+					c1 := out.At(x, y)
+					c2 := out.At(x+sep, y)
+					if c1 != c2 {
+						t.Fatalf("(%v, %v): %#v != %#v", x, y, c1, c2)
+					}
+				}
+			}
+		})
+	}
+}
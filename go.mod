@@ -15,4 +15,5 @@ require (
 	github.com/gopxl/glhf/v2 v2.1.0 // indirect
 	github.com/gopxl/mainthread/v2 v2.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/text v0.34.0 // indirect
 )
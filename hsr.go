@@ -0,0 +1,148 @@
+package sirdsc
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// GenerateHSR generates a new SIRDS from the depth map dm and draws it
+// to out, using the pattern pat. It accepts the same arguments as
+// Generate, but rather than copying pixels from the left, it uses the
+// hidden surface removal algorithm described by Thimbleby, Inglis,
+// and Witten in "Displaying 3D Images: Algorithms for Single Image
+// Random Dot Stereograms". It is equivalent to calling
+// GenerateContext with the HiddenSurface algorithm.
+//
+// For each row, a set of constraints is built that links together
+// pairs of pixels that must be the same color. A link is dropped if
+// the point on the surface that it represents can only be seen by one
+// of the viewer's eyes. This removes most of the echoes and ghosts
+// that Generate produces around sharp changes in depth.
+func GenerateHSR(out draw.Image, dm DepthMap, pat image.Image, partSize int) {
+	err := GenerateContext(context.Background(), out, dm, pat, &Options{
+		PartSize:  partSize,
+		Algorithm: HiddenSurface,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// hsrLabels labels a row using the HiddenSurface algorithm. s.seps
+// must hold the separations for the row.
+//
+// It first builds the constraints for the row by linking each pixel
+// to the leftmost pixel that it must be the same color as. The
+// leftmost pixel of each set of linked pixels then takes its color
+// from the pattern, and the others take their color from it. When
+// propagating outwards, the pixel closest to the center of the
+// origin's strip takes its color from the pattern instead.
+func hsrLabels(g *generator, s *rowScratch) {
+	seps := s.seps
+	width := len(seps)
+
+	// In a wall-eyed stereogram, closer points have smaller
+	// separations, while in a cross-eyed one they have larger ones.
+	// The visibility check needs values that get smaller as points get
+	// closer, so the separations are negated for cross-eyed
+	// stereograms. The depths aren't needed anymore, so their buffer
+	// is reused.
+	s.depth = resize(s.depth, width)
+	dists := s.depth
+	minDist := math.MaxInt
+	for i := range seps {
+		dists[i] = seps[i]
+		if g.cross {
+			dists[i] = -seps[i]
+		}
+		minDist = min(minDist, dists[i])
+	}
+
+	same := s.labels
+	for i := range same {
+		same[i] = i
+	}
+
+	for i, sep := range seps {
+		left := i - sep/2
+		right := left + sep
+		if (sep <= 0) || (left < 0) || (right >= width) {
+			continue
+		}
+
+		if !hsrVisible(dists, i, minDist) {
+			continue
+		}
+
+		for k := same[right]; (k != right) && (k != left); k = same[right] {
+			if k > left {
+				right = k
+				continue
+			}
+			right, left = left, k
+		}
+		same[right] = left
+	}
+
+	// same[i] <= i for every pixel, so resolving the links from left to
+	// right always finds an already resolved pixel.
+	for i, k := range same {
+		same[i] = same[k]
+	}
+
+	x0 := g.frame.Min.X*g.n - g.partSize
+	if !g.out {
+		for i, k := range same {
+			same[i] = x0 + k
+		}
+		return
+	}
+
+	// Find the pixel in each set that is closest to the center of the
+	// strip and give it, and therefore the whole set, its color from
+	// the pattern. The buffer of pending labels is used to record the
+	// chosen pixel for each set, indexed by the set's leftmost pixel.
+	s.pending = resize(s.pending, width)
+	rep := s.pending
+	center := g.origin + g.partSize/2
+	for i, k := range same {
+		if (k == i) || (abs(i-center) < abs(rep[k]-center)) {
+			rep[k] = i
+		}
+	}
+	for i, k := range same {
+		same[i] = x0 + rep[k]
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// hsrVisible reports whether the point at i can be seen by both eyes.
+// dists holds the separation of each point in the row, negated for
+// cross-eyed stereograms, and minDist is the smallest of them.
+//
+// Every point along the ray from a point to one of the eyes passes
+// through the screen at the same place, so as the ray moves one pixel
+// towards that eye, the separation of the point on it changes by two
+// pixels. The point is hidden if the surface at any pixel along either
+// ray is at least as close to the viewer as the ray is.
+func hsrVisible(dists []int, i, minDist int) bool {
+	d := dists[i]
+	for t := 1; d-2*t >= minDist; t++ {
+		ray := d - 2*t
+		if (i-t >= 0) && (dists[i-t] <= ray) {
+			return false
+		}
+		if (i+t < len(dists)) && (dists[i+t] <= ray) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package sirdsc_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+type constDepthMap struct {
+	rect  image.Rectangle
+	depth int
+}
+
+func (dm constDepthMap) Bounds() image.Rectangle {
+	return dm.rect
+}
+
+func (dm constDepthMap) At(x, y int) int {
+	if !(image.Point{x, y}).In(dm.rect) {
+		return 0
+	}
+	return dm.depth
+}
+
+func TestGenerateHSRFlat(t *testing.T) {
+	const partSize = 20
+
+	dm := constDepthMap{rect: image.Rect(0, 0, 100, 10)}
+	pat := sirdsc.RandImage{Seed: 1}
+
+	out1 := image.NewNRGBA(image.Rect(0, 0, 100+partSize, 10))
+	sirdsc.Generate(out1, dm, pat, partSize)
+
+	out2 := image.NewNRGBA(out1.Bounds())
+	sirdsc.GenerateHSR(out2, dm, pat, partSize)
+
+	for y := out1.Rect.Min.Y; y < out1.Rect.Max.Y; y++ {
+		for x := out1.Rect.Min.X; x < out1.Rect.Max.X; x++ {
+			c1 := out1.At(x, y)
+			c2 := out2.At(x, y)
+			if c1 != c2 {
+				t.Fatalf("(%v, %v): %#v != %#v", x, y, c1, c2)
+			}
+		}
+	}
+}
+
+func TestGenerateHSRLinks(t *testing.T) {
+	const (
+		partSize = 20
+		depth    = 6
+	)
+
+	dm := constDepthMap{rect: image.Rect(0, 0, 100, 10), depth: depth}
+	out := image.NewNRGBA(image.Rect(0, 0, 100+partSize, 10))
+	sirdsc.GenerateHSR(out, dm, sirdsc.RandImage{Seed: 1}, partSize)
+
+	sep := partSize - depth
+	for y := out.Rect.Min.Y; y < out.Rect.Max.Y; y++ {
+		for x := 2 * partSize; x+sep < out.Rect.Max.X; x++ {
+			c1 := out.At(x, y)
+			c2 := out.At(x+sep, y)
+			if c1 != c2 {
+				t.Fatalf("(%v, %v): %#v != %#v", x, y, c1, c2)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package mesh
+
+import (
+	"image"
+	"math"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+// Turntable returns the cameras for an animation of frames frames in
+// which the scene spins a full turn around cam.Axis. The cameras are
+// copies of cam with increasing spins, starting at cam.Spin. The last
+// frame is one step before a full turn, so that the animation loops
+// smoothly. If frames is not positive, there are no cameras.
+func Turntable(cam Camera, frames int) []Camera {
+	if frames <= 0 {
+		return nil
+	}
+
+	cams := make([]Camera, frames)
+	for i := range cams {
+		cams[i] = cam
+		cams[i].Spin += 2 * math.Pi * float64(i) / float64(frames)
+	}
+	return cams
+}
+
+// Keyframe is a camera at a point in time on a camera path.
+type Keyframe struct {
+	Time   float64
+	Camera Camera
+}
+
+// Path returns the cameras for an animation of frames frames that
+// follows the path through keys, which must be sorted by time. The
+// frames are spaced evenly in time from the first keyframe to the last
+// one, and the cameras between keyframes are interpolated linearly.
+// The Projection and Axis of a camera between keyframes are not
+// interpolated, and are taken from the keyframe before it, and neither
+// are its Target and Radius unless both keyframes have a Radius. If
+// there are no keyframes or frames is not positive, there are no
+// cameras.
+func Path(keys []Keyframe, frames int) []Camera {
+	if (len(keys) == 0) || (frames <= 0) {
+		return nil
+	}
+
+	cams := make([]Camera, frames)
+	start, end := keys[0].Time, keys[len(keys)-1].Time
+	var k int
+	for i := range cams {
+		t := start
+		if frames > 1 {
+			t += (end - start) * float64(i) / float64(frames-1)
+		}
+		for (k < len(keys)-2) && (keys[k+1].Time <= t) {
+			k++
+		}
+
+		if len(keys) == 1 {
+			cams[i] = keys[0].Camera
+			continue
+		}
+		k0, k1 := keys[k], keys[k+1]
+		f := 0.0
+		if k1.Time > k0.Time {
+			f = min(max((t-k0.Time)/(k1.Time-k0.Time), 0), 1)
+		}
+		if f >= 1 {
+			cams[i] = k1.Camera
+			continue
+		}
+		cams[i] = lerpCamera(k0.Camera, k1.Camera, f)
+	}
+	return cams
+}
+
+// lerpCamera returns the camera that is a fraction t of the way from c1
+// to c2.
+func lerpCamera(c1, c2 Camera, t float64) Camera {
+	lerp := func(v1, v2 float64) float64 { return v1 + (v2-v1)*t }
+	lerpVec := func(v1, v2 Vec) Vec { return v1.Add(v2.Sub(v1).Mul(t)) }
+
+	c := c1
+	c.Rotation = lerpVec(c1.Rotation, c2.Rotation)
+	c.Spin = lerp(c1.Spin, c2.Spin)
+	c.Zoom = lerp(zoom(c1.Zoom), zoom(c2.Zoom))
+	c.FOV = lerp(fov(c1.FOV), fov(c2.FOV))
+	if (c1.Radius > 0) && (c2.Radius > 0) {
+		c.Target = lerpVec(c1.Target, c2.Target)
+		c.Radius = lerp(c1.Radius, c2.Radius)
+	}
+	return c
+}
+
+// RenderFrames renders m through each of cams into a depth map with
+// the bounds r, returning the frames of an animation that can be
+// passed to sirdsc.GenerateFrames.
+func RenderFrames(m *Mesh, cams []Camera, r image.Rectangle, depth float64) []sirdsc.DepthMap {
+	frames := make([]sirdsc.DepthMap, len(cams))
+	for i := range cams {
+		d := sirdsc.NewDepth(r)
+		Render(d, m, &cams[i], depth)
+		frames[i] = d
+	}
+	return frames
+}
@@ -0,0 +1,63 @@
+// Package mesh renders triangle meshes and point clouds into depth
+// maps. Meshes can be loaded from Wavefront OBJ files and from binary
+// and ASCII STL files, and are rendered by a simple z-buffer rasterizer
+// through a Camera. Point clouds can be loaded from PLY and XYZ files,
+// and are rendered through a Camera by splatting each point and
+// filling in the gaps between them.
+package mesh
+
+import "math"
+
+// Vec is a point or direction in three dimensions. The Y axis points
+// up, and the Z axis points towards the viewer.
+type Vec struct {
+	X, Y, Z float64
+}
+
+// Add returns v+v2.
+func (v Vec) Add(v2 Vec) Vec {
+	return Vec{v.X + v2.X, v.Y + v2.Y, v.Z + v2.Z}
+}
+
+// Sub returns v-v2.
+func (v Vec) Sub(v2 Vec) Vec {
+	return Vec{v.X - v2.X, v.Y - v2.Y, v.Z - v2.Z}
+}
+
+// Mul returns v scaled by s.
+func (v Vec) Mul(s float64) Vec {
+	return Vec{v.X * s, v.Y * s, v.Z * s}
+}
+
+// Len returns the length of v.
+func (v Vec) Len() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// Triangle is a triangle with its corners in any order.
+type Triangle [3]Vec
+
+// Mesh is a list of triangles. The triangles don't need to be
+// connected or to enclose a volume, and they are visible from both
+// sides.
+type Mesh struct {
+	Triangles []Triangle
+}
+
+// Bounds returns the corners of the smallest box, aligned with the
+// axes, that contains every triangle of the mesh. If the mesh is empty,
+// both corners are the origin.
+func (m *Mesh) Bounds() (lo, hi Vec) {
+	if len(m.Triangles) == 0 {
+		return Vec{}, Vec{}
+	}
+
+	lo, hi = m.Triangles[0][0], m.Triangles[0][0]
+	for _, t := range m.Triangles {
+		for _, v := range t {
+			lo = Vec{min(lo.X, v.X), min(lo.Y, v.Y), min(lo.Z, v.Z)}
+			hi = Vec{max(hi.X, v.X), max(hi.Y, v.Y), max(hi.Z, v.Z)}
+		}
+	}
+	return lo, hi
+}
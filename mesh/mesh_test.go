@@ -0,0 +1,225 @@
+package mesh_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/mesh"
+)
+
+// square is a square in the XY plane, centered on the origin.
+var square = &mesh.Mesh{
+	Triangles: []mesh.Triangle{
+		{{-1, -1, 0}, {1, -1, 0}, {1, 1, 0}},
+		{{-1, -1, 0}, {1, 1, 0}, {-1, 1, 0}},
+	},
+}
+
+func TestReadOBJ(t *testing.T) {
+	const obj = `# A square.
+o square
+v -1 -1 0
+v 1 -1 0
+vt 0 0
+v 1 1 0
+v -1 1 0 1
+vn 0 0 1
+f 1/1/1 2//1 -2 -1
+`
+
+	m, err := mesh.ReadOBJ(strings.NewReader(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, square) {
+		t.Fatalf("got %v", m.Triangles)
+	}
+
+	_, err = mesh.ReadOBJ(strings.NewReader("v 0 0 0\nf 1 2 3\n"))
+	if err == nil {
+		t.Fatal("read a face with missing vertices without an error")
+	}
+}
+
+func TestReadSTL(t *testing.T) {
+	var ascii bytes.Buffer
+	ascii.WriteString("solid square\n")
+	for _, tri := range square.Triangles {
+		ascii.WriteString("  facet normal 0 0 1\n    outer loop\n")
+		for _, v := range tri {
+			fmt.Fprintf(&ascii, "      vertex %v %v %v\n", v.X, v.Y, v.Z)
+		}
+		ascii.WriteString("    endloop\n  endfacet\n")
+	}
+	ascii.WriteString("endsolid square\n")
+
+	// The header of this binary file starts with "solid" to make sure
+	// that it isn't mistaken for an ASCII file.
+	var bin bytes.Buffer
+	bin.WriteString("solid")
+	bin.Write(make([]byte, 75))
+	binary.Write(&bin, binary.LittleEndian, uint32(len(square.Triangles)))
+	for _, tri := range square.Triangles {
+		binary.Write(&bin, binary.LittleEndian, [3]float32{0, 0, 1})
+		for _, v := range tri {
+			binary.Write(&bin, binary.LittleEndian, [3]float32{float32(v.X), float32(v.Y), float32(v.Z)})
+		}
+		binary.Write(&bin, binary.LittleEndian, uint16(0))
+	}
+
+	for name, data := range map[string][]byte{"ASCII": ascii.Bytes(), "Binary": bin.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			m, err := mesh.ReadSTL(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, square) {
+				t.Fatalf("got %v", m.Triangles)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := sirdsc.NewDepth(image.Rect(0, 0, 100, 100))
+
+	// The square is fitted so that its corners touch the circle that
+	// fits in the depth map, so its sides are about 71 pixels long.
+	mesh.Render(d, square, nil, 30)
+	for _, p := range []image.Point{{50, 50}, {16, 16}, {83, 83}} {
+		if got := d.AtF(p.X, p.Y); got != 15 {
+			t.Errorf("orthographic: %v: %v, want 15", p, got)
+		}
+	}
+	for _, p := range []image.Point{{13, 13}, {86, 50}, {0, 0}} {
+		if got := d.AtF(p.X, p.Y); got != 0 {
+			t.Errorf("orthographic: %v: %v, want 0", p, got)
+		}
+	}
+
+	// Tilting the square back makes its top further away, and the
+	// perspective makes the top narrower than the bottom.
+	cam := &mesh.Camera{
+		Projection: mesh.Perspective,
+		Rotation:   mesh.Vec{X: -math.Pi / 4},
+	}
+	mesh.Render(d, square, cam, 30)
+	top, bottom := d.AtF(50, 40), d.AtF(50, 60)
+	if (top >= 15) || (bottom <= 15) {
+		t.Errorf("perspective: top %v, bottom %v", top, bottom)
+	}
+	width := func(y int) (n int) {
+		for x := range 100 {
+			if d.AtF(x, y) > 0 {
+				n++
+			}
+		}
+		return n
+	}
+	if wt, wb := width(35), width(65); wt >= wb {
+		t.Errorf("perspective: top width %v, bottom width %v", wt, wb)
+	}
+}
+
+func TestTurntable(t *testing.T) {
+	cams := mesh.Turntable(mesh.Camera{Zoom: 2}, 4)
+	if len(cams) != 4 {
+		t.Fatalf("%v cameras", len(cams))
+	}
+	for i, cam := range cams {
+		if want := float64(i) * math.Pi / 2; (cam.Spin != want) || (cam.Zoom != 2) {
+			t.Errorf("%v: spin %v, zoom %v", i, cam.Spin, cam.Zoom)
+		}
+	}
+
+	// A quarter turn around the Y axis turns the square edge-on.
+	d := sirdsc.NewDepth(image.Rect(0, 0, 50, 50))
+	mesh.Render(d, square, &cams[1], 30)
+	for _, v := range d.Pix {
+		if v != 0 {
+			t.Fatalf("edge-on square has depth %v", v)
+		}
+	}
+
+	for _, frames := range []int{0, -1} {
+		if cams := mesh.Turntable(mesh.Camera{}, frames); cams != nil {
+			t.Errorf("%v frames: %v cameras", frames, len(cams))
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	cams := mesh.Path([]mesh.Keyframe{
+		{Time: 0, Camera: mesh.Camera{}},
+		{Time: 1, Camera: mesh.Camera{Zoom: 3, Rotation: mesh.Vec{Y: math.Pi}}},
+		{Time: 3, Camera: mesh.Camera{Zoom: 3, Projection: mesh.Perspective}},
+	}, 7)
+
+	want := []struct {
+		zoom, y float64
+	}{
+		{1, 0},
+		{2, math.Pi / 2},
+		{3, math.Pi},
+		{3, 3 * math.Pi / 4},
+		{3, math.Pi / 2},
+		{3, math.Pi / 4},
+		{3, 0},
+	}
+	for i, want := range want {
+		cam := cams[i]
+		if (math.Abs(cam.Zoom-want.zoom) > 1e-9) || (math.Abs(cam.Rotation.Y-want.y) > 1e-9) {
+			t.Errorf("%v: zoom %v, rotation %v", i, cam.Zoom, cam.Rotation.Y)
+		}
+	}
+	if cams[6].Projection != mesh.Perspective {
+		t.Errorf("last projection is %v", cams[6].Projection)
+	}
+
+	if cams := mesh.Path([]mesh.Keyframe{{}}, -1); cams != nil {
+		t.Errorf("-1 frames: %v cameras", len(cams))
+	}
+}
+
+func TestRays(t *testing.T) {
+	lo, hi := square.Bounds()
+	cams := map[string]*mesh.Camera{
+		"Orthographic": {Rotation: mesh.Vec{X: 0.3, Y: -0.5}},
+		"Perspective":  {Projection: mesh.Perspective, Rotation: mesh.Vec{Y: 1}, Zoom: 2},
+	}
+	for name, cam := range cams {
+		t.Run(name, func(t *testing.T) {
+			// The ray through the center of the screen passes through the
+			// center of the scene.
+			rays := cam.Rays(lo, hi, image.Rect(0, 0, 100, 80), 30)
+			origin, dir, length := rays.Ray(50, 40)
+			if mid := origin.Add(dir.Mul(length / 2)); mid.Len() > 1e-9 {
+				t.Errorf("center ray passes through %v", mid)
+			}
+
+			for _, p := range []image.Point{{50, 40}, {10, 70}, {93, 5}} {
+				x, y := float64(p.X)+0.5, float64(p.Y)+0.5
+				origin, dir, length = rays.Ray(x, y)
+				if math.Abs(dir.Len()-1) > 1e-9 {
+					t.Errorf("%v: direction %v", p, dir)
+				}
+
+				// The ray starts at the front of the depth range and ends
+				// at the back of it.
+				if d := rays.Depth(origin); math.Abs(d-30) > 1e-9 {
+					t.Errorf("%v: depth of origin %v", p, d)
+				}
+				if d := rays.Depth(origin.Add(dir.Mul(length))); math.Abs(d) > 1e-9 {
+					t.Errorf("%v: depth of end %v", p, d)
+				}
+			}
+		})
+	}
+}
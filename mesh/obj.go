@@ -0,0 +1,81 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadOBJ reads a mesh from a Wavefront OBJ file. Only the vertices and
+// faces are used, and faces with more than three corners are split into
+// triangles. Everything else, such as normals, texture coordinates and
+// materials, is ignored.
+func ReadOBJ(r io.Reader) (*Mesh, error) {
+	var verts []Vec
+	var m Mesh
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %v: vertex has fewer than three coordinates", line)
+			}
+			var v [3]float64
+			for i := range v {
+				c, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %v: %w", line, err)
+				}
+				v[i] = c
+			}
+			verts = append(verts, Vec{v[0], v[1], v[2]})
+
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %v: face has fewer than three corners", line)
+			}
+			face := make([]Vec, 0, len(fields)-1)
+			for _, f := range fields[1:] {
+				v, err := objVertex(f, verts)
+				if err != nil {
+					return nil, fmt.Errorf("line %v: %w", line, err)
+				}
+				face = append(face, v)
+			}
+			for i := 2; i < len(face); i++ {
+				m.Triangles = append(m.Triangles, Triangle{face[0], face[i-1], face[i]})
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// objVertex returns the vertex referred to by a corner of a face, which
+// has the form v, v/vt, v//vn, or v/vt/vn. Negative indices count back
+// from the most recently read vertex.
+func objVertex(corner string, verts []Vec) (Vec, error) {
+	index, _, _ := strings.Cut(corner, "/")
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return Vec{}, err
+	}
+	if i < 0 {
+		i += len(verts) + 1
+	}
+	if (i < 1) || (i > len(verts)) {
+		return Vec{}, fmt.Errorf("vertex %v does not exist", index)
+	}
+	return verts[i-1], nil
+}
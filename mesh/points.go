@@ -0,0 +1,328 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PointCloud is a set of points, such as one produced by a lidar
+// scanner or by photogrammetry.
+type PointCloud struct {
+	Points []Vec
+}
+
+// Bounds returns the corners of the smallest box, aligned with the
+// axes, that contains every point. If the point cloud is empty, both
+// corners are the origin.
+func (pc *PointCloud) Bounds() (lo, hi Vec) {
+	if len(pc.Points) == 0 {
+		return Vec{}, Vec{}
+	}
+
+	lo, hi = pc.Points[0], pc.Points[0]
+	for _, v := range pc.Points {
+		lo = Vec{min(lo.X, v.X), min(lo.Y, v.Y), min(lo.Z, v.Z)}
+		hi = Vec{max(hi.X, v.X), max(hi.Y, v.Y), max(hi.Z, v.Z)}
+	}
+	return lo, hi
+}
+
+// ReadXYZ reads a point cloud from a text file with a point on every
+// line. The first three fields of each line, which can be separated by
+// spaces, tabs, commas, or semicolons, are the point's coordinates, and
+// the rest, such as colors, are ignored. Empty lines and lines starting
+// with # are skipped.
+func ReadXYZ(r io.Reader) (*PointCloud, error) {
+	var pc PointCloud
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if (text == "") || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(text, func(c rune) bool {
+			return (c == ' ') || (c == '\t') || (c == ',') || (c == ';')
+		})
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %v: point has fewer than three coordinates", line)
+		}
+		var v [3]float64
+		for i := range v {
+			c, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %w", line, err)
+			}
+			v[i] = c
+		}
+		pc.Points = append(pc.Points, Vec{v[0], v[1], v[2]})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+// plyProperty is a property of an element in a PLY file.
+type plyProperty struct {
+	name string
+	typ  string
+
+	// count is the type of the length of a list property, or empty if
+	// the property isn't a list.
+	count string
+}
+
+// plyElement is the declaration of an element in a PLY file.
+type plyElement struct {
+	name  string
+	n     int
+	props []plyProperty
+}
+
+// maxPLYElements is the largest number of items that an element of a
+// PLY file can declare. Larger counts are assumed to be corrupt.
+const maxPLYElements = 1 << 30
+
+// plySizes are the sizes in bytes of the types of PLY properties.
+var plySizes = map[string]int{
+	"char": 1, "int8": 1,
+	"uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2,
+	"ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4,
+	"uint": 4, "uint32": 4,
+	"float": 4, "float32": 4,
+	"double": 8, "float64": 8,
+}
+
+// ReadPLY reads a point cloud from the vertices of an ASCII or binary
+// PLY file. Everything other than the x, y, and z properties of the
+// vertices, including faces, is ignored.
+func ReadPLY(r io.Reader) (*PointCloud, error) {
+	br := bufio.NewReader(r)
+	format, elems, err := readPLYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var read func(typ string) (float64, error)
+	switch format {
+	case "ascii":
+		read = plyASCIIReader(br)
+	case "binary_little_endian":
+		read = plyBinaryReader(br, binary.LittleEndian)
+	case "binary_big_endian":
+		read = plyBinaryReader(br, binary.BigEndian)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	for _, elem := range elems {
+		if elem.name != "vertex" {
+			err := skipPLYElement(elem, read)
+			if err != nil {
+				return nil, fmt.Errorf("read %v: %w", elem.name, err)
+			}
+			continue
+		}
+
+		// The vertices are all that's needed, so the rest of the file
+		// isn't read.
+		return readPLYVertices(elem, read)
+	}
+	return nil, errors.New("no vertex element")
+}
+
+func readPLYHeader(r *bufio.Reader) (format string, elems []plyElement, err error) {
+	line, _ := r.ReadString('\n')
+	if strings.TrimSpace(line) != "ply" {
+		return "", nil, errors.New("not a PLY file")
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", nil, fmt.Errorf("read header: %w", err)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "end_header":
+			if format == "" {
+				return "", nil, errors.New("no format")
+			}
+			return format, elems, nil
+
+		case "format":
+			if len(fields) < 2 {
+				return "", nil, errors.New("invalid format")
+			}
+			format = fields[1]
+
+		case "element":
+			if len(fields) < 3 {
+				return "", nil, fmt.Errorf("invalid element: %q", strings.TrimSpace(line))
+			}
+			n, err := strconv.Atoi(fields[2])
+			if (err != nil) || (n < 0) || (n > maxPLYElements) {
+				return "", nil, fmt.Errorf("invalid element count: %q", fields[2])
+			}
+			elems = append(elems, plyElement{name: fields[1], n: n})
+
+		case "property":
+			if len(elems) == 0 {
+				return "", nil, errors.New("property before any element")
+			}
+			var prop plyProperty
+			switch {
+			case (len(fields) == 5) && (fields[1] == "list"):
+				prop = plyProperty{name: fields[4], typ: fields[3], count: fields[2]}
+				if _, ok := plySizes[prop.count]; !ok {
+					return "", nil, fmt.Errorf("unknown type %q", prop.count)
+				}
+			case len(fields) == 3:
+				prop = plyProperty{name: fields[2], typ: fields[1]}
+			default:
+				return "", nil, fmt.Errorf("invalid property: %q", strings.TrimSpace(line))
+			}
+			if _, ok := plySizes[prop.typ]; !ok {
+				return "", nil, fmt.Errorf("unknown type %q", prop.typ)
+			}
+			e := &elems[len(elems)-1]
+			e.props = append(e.props, prop)
+		}
+	}
+}
+
+// plyASCIIReader returns a function that reads the next value from the
+// body of an ASCII PLY file.
+func plyASCIIReader(r *bufio.Reader) func(typ string) (float64, error) {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+	return func(typ string) (float64, error) {
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+		return strconv.ParseFloat(s.Text(), 64)
+	}
+}
+
+// plyBinaryReader returns a function that reads the next value from
+// the body of a binary PLY file.
+func plyBinaryReader(r *bufio.Reader, order binary.ByteOrder) func(typ string) (float64, error) {
+	var buf [8]byte
+	return func(typ string) (float64, error) {
+		b := buf[:plySizes[typ]]
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+
+		switch typ {
+		case "char", "int8":
+			return float64(int8(b[0])), nil
+		case "uchar", "uint8":
+			return float64(b[0]), nil
+		case "short", "int16":
+			return float64(int16(order.Uint16(b))), nil
+		case "ushort", "uint16":
+			return float64(order.Uint16(b)), nil
+		case "int", "int32":
+			return float64(int32(order.Uint32(b))), nil
+		case "uint", "uint32":
+			return float64(order.Uint32(b)), nil
+		case "float", "float32":
+			return float64(math.Float32frombits(order.Uint32(b))), nil
+		default:
+			return math.Float64frombits(order.Uint64(b)), nil
+		}
+	}
+}
+
+// readPLYProperty reads a property, returning its value, or its last
+// value if it is a list.
+func readPLYProperty(prop plyProperty, read func(string) (float64, error)) (float64, error) {
+	if prop.count == "" {
+		return read(prop.typ)
+	}
+
+	n, err := read(prop.count)
+	if err != nil {
+		return 0, err
+	}
+	var v float64
+	for range int(n) {
+		v, err = read(prop.typ)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return v, nil
+}
+
+func skipPLYElement(elem plyElement, read func(string) (float64, error)) error {
+	for range elem.n {
+		for _, prop := range elem.props {
+			_, err := readPLYProperty(prop, read)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readPLYVertices(elem plyElement, read func(string) (float64, error)) (*PointCloud, error) {
+	coords := [3]int{-1, -1, -1}
+	for i, prop := range elem.props {
+		switch prop.name {
+		case "x":
+			coords[0] = i
+		case "y":
+			coords[1] = i
+		case "z":
+			coords[2] = i
+		}
+	}
+	if (coords[0] < 0) || (coords[1] < 0) || (coords[2] < 0) {
+		return nil, errors.New("vertices don't have x, y, and z properties")
+	}
+
+	// The count in the header isn't trusted to size the points up
+	// front, so that a file can't claim a huge number of vertices that
+	// it doesn't have.
+	pc := PointCloud{Points: make([]Vec, 0, min(elem.n, 1<<16))}
+	values := make([]float64, len(elem.props))
+	for i := range elem.n {
+		for p, prop := range elem.props {
+			v, err := readPLYProperty(prop, read)
+			if err != nil {
+				return nil, fmt.Errorf("read vertex %v: %w", i, err)
+			}
+			values[p] = v
+		}
+		pc.Points = append(pc.Points, Vec{values[coords[0]], values[coords[1]], values[coords[2]]})
+	}
+	return &pc, nil
+}
@@ -0,0 +1,151 @@
+package mesh_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/mesh"
+)
+
+var points = []mesh.Vec{{0, 0, 0}, {1.5, -2, 3}, {-1, 0.25, 2}}
+
+func TestReadXYZ(t *testing.T) {
+	const xyz = `# x y z r g b
+0 0 0 255 0 0
+
+1.5,-2,3
+-1;0.25	2
+`
+
+	pc, err := mesh.ReadXYZ(strings.NewReader(xyz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pc.Points, points) {
+		t.Fatalf("got %v", pc.Points)
+	}
+
+	_, err = mesh.ReadXYZ(strings.NewReader("1 2\n"))
+	if err == nil {
+		t.Fatal("read a point with two coordinates without an error")
+	}
+}
+
+func TestReadPLY(t *testing.T) {
+	const ascii = `ply
+format ascii 1.0
+comment A face before the vertices.
+element face 1
+property list uchar int vertex_indices
+element vertex 3
+property uchar red
+property float z
+property float x
+property float y
+end_header
+3 0 1 2
+255 0 0 0
+0 3 1.5 -2
+7 2 -1 0.25
+`
+
+	// The binary files have a face that comes before the vertices to
+	// make sure that list properties are skipped correctly.
+	binaryPLY := func(format string, order binary.ByteOrder) []byte {
+		var buf bytes.Buffer
+		buf.WriteString("ply\nformat " + format + " 1.0\n")
+		buf.WriteString("element face 1\nproperty list uchar int vertex_indices\n")
+		buf.WriteString("element vertex 3\nproperty double x\nproperty double y\nproperty double z\nproperty short s\n")
+		buf.WriteString("end_header\n")
+		binary.Write(&buf, order, uint8(3))
+		binary.Write(&buf, order, [3]int32{0, 1, 2})
+		for _, p := range points {
+			binary.Write(&buf, order, [3]float64{p.X, p.Y, p.Z})
+			binary.Write(&buf, order, int16(-1))
+		}
+		return buf.Bytes()
+	}
+
+	tests := map[string][]byte{
+		"ASCII":        []byte(ascii),
+		"LittleEndian": binaryPLY("binary_little_endian", binary.LittleEndian),
+		"BigEndian":    binaryPLY("binary_big_endian", binary.BigEndian),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			pc, err := mesh.ReadPLY(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pc.Points, points) {
+				t.Fatalf("got %v", pc.Points)
+			}
+		})
+	}
+
+	_, err := mesh.ReadPLY(bytes.NewReader(tests["LittleEndian"][:len(tests["LittleEndian"])-5]))
+	if err == nil {
+		t.Fatal("read a truncated file without an error")
+	}
+
+	for _, n := range []string{"4000000000000000000", "100000000"} {
+		header := "ply\nformat ascii 1.0\nelement vertex " + n + "\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n"
+		_, err = mesh.ReadPLY(strings.NewReader(header))
+		if err == nil {
+			t.Errorf("read %v vertices from a file with one without an error", n)
+		}
+	}
+}
+
+func TestRenderPoints(t *testing.T) {
+	// A grid of points in the XY plane that are 4 pixels apart once
+	// they're fitted into the depth map.
+	var pc mesh.PointCloud
+	for y := range 11 {
+		for x := range 11 {
+			pc.Points = append(pc.Points, mesh.Vec{X: float64(x), Y: float64(y)})
+		}
+	}
+	cam := &mesh.Camera{Target: mesh.Vec{X: 5, Y: 5}, Radius: 6.25}
+
+	covered := func(d *sirdsc.Depth) (n int) {
+		for _, v := range d.Pix {
+			switch v {
+			case 0:
+			case 10:
+				n++
+			default:
+				t.Fatalf("depth %v", v)
+			}
+		}
+		return n
+	}
+
+	d := sirdsc.NewDepth(image.Rect(0, 0, 50, 50))
+	mesh.RenderPoints(d, &pc, cam, 20, nil)
+	if n := covered(d); n != len(pc.Points) {
+		t.Fatalf("%v points covered without splatting", n)
+	}
+
+	// Each point is on the corner of four pixels, all of whose centers
+	// are within a radius of 1 of it.
+	mesh.RenderPoints(d, &pc, cam, 20, &mesh.SplatOptions{Radius: 1})
+	if n := covered(d); n != 4*len(pc.Points) {
+		t.Fatalf("%v points covered with a radius of 1", n)
+	}
+
+	// Filling the gaps should cover the whole square that the points
+	// are in, but nothing outside of it.
+	mesh.RenderPoints(d, &pc, cam, 20, &mesh.SplatOptions{Fill: 2})
+	if n := covered(d); n != 41*41 {
+		t.Fatalf("%v points covered with filling", n)
+	}
+	if (d.AtF(4, 5) != 0) || (d.AtF(5, 5) != 10) || (d.AtF(45, 45) != 10) || (d.AtF(46, 45) != 0) {
+		t.Fatal("filled outside of the points")
+	}
+}
@@ -0,0 +1,360 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+// DefaultFOV is the field of view used by perspective cameras if none
+// is specified.
+const DefaultFOV = math.Pi / 4
+
+// Projection is the way that a Camera projects a scene onto the screen.
+type Projection int
+
+const (
+	// Orthographic projects the scene straight onto the screen, so
+	// things don't get smaller the further away they are.
+	Orthographic Projection = iota
+
+	// Perspective projects the scene as it would look from a point in
+	// front of it.
+	Perspective
+)
+
+func (p Projection) String() string {
+	switch p {
+	case Orthographic:
+		return "orthographic"
+	case Perspective:
+		return "perspective"
+	default:
+		return fmt.Sprintf("Projection(%d)", int(p))
+	}
+}
+
+// Camera is a view of a scene. The camera looks at Target along the
+// negative Z axis, from in front of it, and frames the sphere around
+// Target with a radius of Radius so that it fits the screen. The front
+// of the sphere is mapped to the maximum depth, and the back of it is
+// mapped to zero.
+//
+// The zero value is an orthographic camera that is fitted to the scene.
+type Camera struct {
+	Projection Projection
+
+	// Rotation rotates the scene around Target before it is viewed. Its
+	// components are angles in radians that the scene is rotated by
+	// around the X, Y, and Z axes, in that order.
+	Rotation Vec
+
+	// Spin rotates the scene by an angle in radians around Axis, which
+	// passes through Target, before Rotation is applied. Turntable
+	// animations are made by changing it. If Axis is the zero vector,
+	// the Y axis is used, so that the scene spins around its vertical
+	// axis.
+	Spin float64
+	Axis Vec
+
+	// Zoom scales the view, zooming in if it is larger than 1. If it is
+	// zero, it is 1.
+	Zoom float64
+
+	// FOV is the angle in radians that the smallest dimension of the
+	// screen covers with a perspective projection. If it is zero,
+	// DefaultFOV is used.
+	FOV float64
+
+	// Target and Radius are the center and radius of the part of the
+	// scene to view. If Radius is zero, they are fitted to the bounds of
+	// the scene instead, so that the whole scene is visible at any
+	// rotation.
+	Target Vec
+	Radius float64
+}
+
+// view is a Camera that has been set up to project points from a
+// particular scene onto a particular depth map.
+type view struct {
+	rot    [3][3]float64
+	target Vec
+	radius float64
+	persp  bool
+
+	// dist is the distance from a perspective camera to target.
+	dist float64
+
+	// scale is the number of pixels per unit at target.
+	scale  float64
+	cx, cy float64
+
+	max float64
+}
+
+// view returns a view of a scene whose bounds are from lo to hi,
+// projected onto r with depths in the range [0, depth].
+func (cam *Camera) view(lo, hi Vec, r image.Rectangle, depth float64) *view {
+	if cam == nil {
+		cam = &Camera{}
+	}
+
+	v := view{
+		rot:    mul(Rotation(cam.Rotation), axisRotation(cam.Axis, cam.Spin)),
+		target: cam.Target,
+		radius: cam.Radius,
+		persp:  cam.Projection == Perspective,
+		cx:     float64(r.Min.X+r.Max.X) / 2,
+		cy:     float64(r.Min.Y+r.Max.Y) / 2,
+		max:    depth,
+	}
+	if v.radius <= 0 {
+		v.target = lo.Add(hi).Mul(0.5)
+		v.radius = hi.Sub(lo).Len() / 2
+	}
+	if v.radius <= 0 {
+		// The scene is a single point or is empty.
+		v.radius = 1
+	}
+
+	v.scale = float64(min(r.Dx(), r.Dy())) / 2 / v.radius * zoom(cam.Zoom)
+
+	if v.persp {
+		v.dist = v.radius / math.Sin(fov(cam.FOV)/2)
+
+		// The scale is for points in the plane through target, which is
+		// the plane that the sphere is fitted in.
+		v.scale *= math.Sqrt(v.dist*v.dist-v.radius*v.radius) / v.dist
+	}
+
+	return &v
+}
+
+// zoom returns the zoom of a camera with a Zoom of z.
+func zoom(z float64) float64 {
+	if z <= 0 {
+		return 1
+	}
+	return z
+}
+
+// fov returns the field of view of a camera with an FOV of f.
+func fov(f float64) float64 {
+	if f <= 0 {
+		return DefaultFOV
+	}
+	return f
+}
+
+// project returns the position of p on the screen and its nearness, a
+// value that is larger the closer p is and that can be interpolated
+// linearly across the screen. If p is behind a perspective camera, ok
+// is false.
+func (v *view) project(p Vec) (x, y, q float64, ok bool) {
+	p = p.Sub(v.target)
+	p = Vec{
+		v.rot[0][0]*p.X + v.rot[0][1]*p.Y + v.rot[0][2]*p.Z,
+		v.rot[1][0]*p.X + v.rot[1][1]*p.Y + v.rot[1][2]*p.Z,
+		v.rot[2][0]*p.X + v.rot[2][1]*p.Y + v.rot[2][2]*p.Z,
+	}
+
+	if !v.persp {
+		return v.cx + p.X*v.scale, v.cy - p.Y*v.scale, p.Z, true
+	}
+
+	z := v.dist - p.Z
+	if z <= 0 {
+		return 0, 0, 0, false
+	}
+	s := v.scale * v.dist / z
+	return v.cx + p.X*s, v.cy - p.Y*s, 1 / z, true
+}
+
+// depth converts a nearness returned by project to a depth.
+func (v *view) depth(q float64) float64 {
+	z := q
+	if v.persp {
+		z = v.dist - 1/q
+	}
+	return min(max((z+v.radius)/(2*v.radius), 0), 1) * v.max
+}
+
+// Rays casts rays through a Camera from the pixels of a depth map. It
+// can be used to render scenes that aren't made of triangles, such as
+// ones that are ray marched, with the same framing and depths as
+// Render.
+type Rays struct {
+	v *view
+}
+
+// Rays returns the Rays of cam for a scene whose bounds are from lo to
+// hi, projected onto r with depths in the range [0, depth]. If cam is
+// nil, the zero Camera is used.
+func (cam *Camera) Rays(lo, hi Vec, r image.Rectangle, depth float64) *Rays {
+	return &Rays{v: cam.view(lo, hi, r, depth)}
+}
+
+// Ray returns the part of the ray through the point (x, y) of the
+// screen that is between the front and the back of the sphere that the
+// camera frames. The ray starts at origin, goes in the direction dir,
+// which has a length of 1, and is length long. The centers of pixels
+// are at half-integer coordinates.
+func (rs *Rays) Ray(x, y float64) (origin, dir Vec, length float64) {
+	v := rs.v
+
+	// The ray is calculated in the camera's space, in which the camera
+	// looks at target along the negative Z axis, and then rotated back
+	// into the scene's.
+	sx, sy := (x-v.cx)/v.scale, -(y-v.cy)/v.scale
+	if !v.persp {
+		origin, dir, length = Vec{sx, sy, v.radius}, Vec{Z: -1}, 2*v.radius
+	} else {
+		dir = Vec{sx, sy, -v.dist}
+		dir = dir.Mul(1 / dir.Len())
+		t0 := (v.radius - v.dist) / dir.Z
+		t1 := (-v.radius - v.dist) / dir.Z
+		origin, length = Vec{Z: v.dist}.Add(dir.Mul(t0)), t1-t0
+	}
+
+	return v.unrotate(origin).Add(v.target), v.unrotate(dir), length
+}
+
+// Depth returns the depth that p, a point in the scene, is rendered at.
+func (rs *Rays) Depth(p Vec) float64 {
+	_, _, q, ok := rs.v.project(p)
+	if !ok {
+		return 0
+	}
+	return rs.v.depth(q)
+}
+
+// unrotate rotates p from the camera's space back into the scene's.
+func (v *view) unrotate(p Vec) Vec {
+	return Vec{
+		v.rot[0][0]*p.X + v.rot[1][0]*p.Y + v.rot[2][0]*p.Z,
+		v.rot[0][1]*p.X + v.rot[1][1]*p.Y + v.rot[2][1]*p.Z,
+		v.rot[0][2]*p.X + v.rot[1][2]*p.Y + v.rot[2][2]*p.Z,
+	}
+}
+
+// Rotation returns the matrix that rotates by r.X around the X axis,
+// then by r.Y around the Y axis, and then by r.Z around the Z axis, as
+// a Camera does with its Rotation. A point p is rotated by the matrix m
+// by multiplying them, so that the rotated X coordinate is
+// m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z.
+func Rotation(r Vec) [3][3]float64 {
+	sx, cx := math.Sincos(r.X)
+	sy, cy := math.Sincos(r.Y)
+	sz, cz := math.Sincos(r.Z)
+	return [3][3]float64{
+		{cy * cz, sx*sy*cz - cx*sz, cx*sy*cz + sx*sz},
+		{cy * sz, sx*sy*sz + cx*cz, cx*sy*sz - sx*cz},
+		{-sy, sx * cy, cx * cy},
+	}
+}
+
+// axisRotation returns the matrix that rotates by angle around axis.
+// If axis is the zero vector, the Y axis is used.
+func axisRotation(axis Vec, angle float64) [3][3]float64 {
+	l := axis.Len()
+	if l == 0 {
+		axis, l = Vec{Y: 1}, 1
+	}
+	x, y, z := axis.X/l, axis.Y/l, axis.Z/l
+
+	// This is Rodrigues' rotation formula.
+	s, c := math.Sincos(angle)
+	t := 1 - c
+	return [3][3]float64{
+		{t*x*x + c, t*x*y - s*z, t*x*z + s*y},
+		{t*x*y + s*z, t*y*y + c, t*y*z - s*x},
+		{t*x*z - s*y, t*y*z + s*x, t*z*z + c},
+	}
+}
+
+// mul returns the matrix product a×b, which rotates by b and then by a.
+func mul(a, b [3][3]float64) (m [3][3]float64) {
+	for i := range 3 {
+		for j := range 3 {
+			m[i][j] = a[i][0]*b[0][j] + a[i][1]*b[1][j] + a[i][2]*b[2][j]
+		}
+	}
+	return m
+}
+
+// Render renders m through cam into dst, which is entirely
+// overwritten. The scene is fitted to the bounds of dst, and its depths
+// are in the range [0, depth]. Points that nothing covers have a depth
+// of zero. If cam is nil, the zero Camera is used.
+func Render(dst *sirdsc.Depth, m *Mesh, cam *Camera, depth float64) {
+	r := dst.Rect
+	lo, hi := m.Bounds()
+	v := cam.view(lo, hi, r, depth)
+
+	zbuf := make([]float64, r.Dx()*r.Dy())
+	for i := range zbuf {
+		zbuf[i] = math.Inf(-1)
+	}
+
+	for _, t := range m.Triangles {
+		var px, py, pq [3]float64
+		visible := true
+		for i, p := range t {
+			var ok bool
+			px[i], py[i], pq[i], ok = v.project(p)
+			visible = visible && ok
+		}
+		if visible {
+			rasterize(zbuf, r, px, py, pq)
+		}
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		for i, q := range zbuf[(y-r.Min.Y)*r.Dx() : (y-r.Min.Y+1)*r.Dx()] {
+			row[i] = 0
+			if !math.IsInf(q, -1) {
+				row[i] = float32(v.depth(q))
+			}
+		}
+	}
+}
+
+// rasterize draws the triangle with the corners (x[i], y[i]) into
+// zbuf, which holds the nearness of every point of r. The nearnesses
+// at the corners are q, and every pixel whose center is in the
+// triangle is set to the interpolated nearness if it is nearer than
+// what is already there.
+func rasterize(zbuf []float64, r image.Rectangle, x, y, q [3]float64) {
+	area := (x[1]-x[0])*(y[2]-y[0]) - (x[2]-x[0])*(y[1]-y[0])
+	if area == 0 {
+		return
+	}
+
+	x0 := max(int(math.Floor(min(x[0], x[1], x[2]))), r.Min.X)
+	x1 := min(int(math.Ceil(max(x[0], x[1], x[2]))), r.Max.X)
+	y0 := max(int(math.Floor(min(y[0], y[1], y[2]))), r.Min.Y)
+	y1 := min(int(math.Ceil(max(y[0], y[1], y[2]))), r.Max.Y)
+
+	for py := y0; py < y1; py++ {
+		cy := float64(py) + 0.5
+		for px := x0; px < x1; px++ {
+			cx := float64(px) + 0.5
+
+			// The barycentric coordinates of the pixel center. They all
+			// have the same sign as the area inside of the triangle, no
+			// matter which way it is wound.
+			w0 := ((x[1]-cx)*(y[2]-cy) - (x[2]-cx)*(y[1]-cy)) / area
+			w1 := ((x[2]-cx)*(y[0]-cy) - (x[0]-cx)*(y[2]-cy)) / area
+			w2 := 1 - w0 - w1
+			if (w0 < 0) || (w1 < 0) || (w2 < 0) {
+				continue
+			}
+
+			i := (py-r.Min.Y)*r.Dx() + (px - r.Min.X)
+			zbuf[i] = max(zbuf[i], w0*q[0]+w1*q[1]+w2*q[2])
+		}
+	}
+}
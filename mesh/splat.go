@@ -0,0 +1,182 @@
+package mesh
+
+import (
+	"image"
+	"math"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+// SplatOptions are the options for rendering a point cloud.
+type SplatOptions struct {
+	// Radius is the radius in pixels of the disc that each point is
+	// drawn as. If it is zero, each point only covers the pixel that it
+	// is in.
+	Radius float64
+
+	// Fill is the size in pixels of the largest gaps between points
+	// that are filled in. Gaps up to about twice Fill wide are closed,
+	// and the depths in them are averaged from the points around them.
+	// The outside edges of the point cloud aren't grown. If it is zero,
+	// no gaps are filled.
+	Fill int
+}
+
+// RenderPoints renders pc through cam into dst, which is entirely
+// overwritten, in the same way that Render renders a mesh. If cam or
+// opts is nil, their zero values are used.
+func RenderPoints(dst *sirdsc.Depth, pc *PointCloud, cam *Camera, depth float64, opts *SplatOptions) {
+	if opts == nil {
+		opts = &SplatOptions{}
+	}
+
+	r := dst.Rect
+	lo, hi := pc.Bounds()
+	v := cam.view(lo, hi, r, depth)
+
+	zbuf := make([]float64, r.Dx()*r.Dy())
+	for i := range zbuf {
+		zbuf[i] = math.Inf(-1)
+	}
+
+	for _, p := range pc.Points {
+		x, y, q, ok := v.project(p)
+		if ok {
+			splat(zbuf, r, x, y, q, opts.Radius)
+		}
+	}
+	if opts.Fill > 0 {
+		fillGaps(zbuf, r.Dx(), r.Dy(), opts.Fill)
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		for i, q := range zbuf[(y-r.Min.Y)*r.Dx() : (y-r.Min.Y+1)*r.Dx()] {
+			row[i] = 0
+			if !math.IsInf(q, -1) {
+				row[i] = float32(v.depth(q))
+			}
+		}
+	}
+}
+
+// splat draws a disc with a radius of radius and a nearness of q
+// centered on (x, y) into zbuf, which holds the nearness of every point
+// of r.
+func splat(zbuf []float64, r image.Rectangle, x, y, q, radius float64) {
+	x0 := max(int(math.Floor(x-radius)), r.Min.X)
+	x1 := min(int(math.Floor(x+radius))+1, r.Max.X)
+	y0 := max(int(math.Floor(y-radius)), r.Min.Y)
+	y1 := min(int(math.Floor(y+radius))+1, r.Max.Y)
+
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			// The pixel that the point is in is always covered, even if
+			// its center is outside of the disc.
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if (dx*dx+dy*dy > radius*radius) && ((px != int(math.Floor(x))) || (py != int(math.Floor(y)))) {
+				continue
+			}
+
+			i := (py-r.Min.Y)*r.Dx() + (px - r.Min.X)
+			zbuf[i] = max(zbuf[i], q)
+		}
+	}
+}
+
+// fillGaps fills the gaps in zbuf, which is w by h and in which empty
+// points are negative infinity, that are up to about 2*n wide. It does
+// so by finding the points that a morphological closing of the covered
+// points with a square of side 2*n+1 adds, and setting each of them to
+// the average of the covered points within n of it.
+func fillGaps(zbuf []float64, w, h, n int) {
+	covered := make([]bool, len(zbuf))
+	for i, q := range zbuf {
+		covered[i] = !math.IsInf(q, -1)
+	}
+
+	// Points outside of zbuf count as covered while eroding so that gaps
+	// along its edges are filled, too.
+	closed := morph(morph(covered, w, h, n, false), w, h, n, true)
+
+	// Summed-area tables of the covered nearnesses and of the number of
+	// covered points make the averages cheap to calculate.
+	sum := make([]float64, (w+1)*(h+1))
+	count := make([]int, (w+1)*(h+1))
+	for y := range h {
+		for x := range w {
+			i := (y+1)*(w+1) + (x + 1)
+			sum[i] = sum[i-1] + sum[i-(w+1)] - sum[i-(w+1)-1]
+			count[i] = count[i-1] + count[i-(w+1)] - count[i-(w+1)-1]
+			if covered[y*w+x] {
+				sum[i] += zbuf[y*w+x]
+				count[i]++
+			}
+		}
+	}
+	area := func(t []float64, c []int, x0, y0, x1, y1 int) (float64, int) {
+		a, b, d, e := y0*(w+1)+x0, y0*(w+1)+x1, y1*(w+1)+x0, y1*(w+1)+x1
+		return t[e] - t[b] - t[d] + t[a], c[e] - c[b] - c[d] + c[a]
+	}
+
+	for y := range h {
+		for x := range w {
+			i := y*w + x
+			if covered[i] || !closed[i] {
+				continue
+			}
+
+			s, c := area(sum, count, max(x-n, 0), max(y-n, 0), min(x+n+1, w), min(y+n+1, h))
+			if c > 0 {
+				zbuf[i] = s / float64(c)
+			}
+		}
+	}
+}
+
+// morph returns the dilation, or the erosion if erode is true, of mask,
+// which is w by h, with a square of side 2*n+1. Points outside of mask
+// are treated as unset when dilating and as set when eroding.
+func morph(mask []bool, w, h, n int, erode bool) []bool {
+	// The square is separable, so the mask is processed horizontally
+	// and then vertically. Each point is set if any point in the window
+	// around it is set, or, when eroding, if all of them are.
+	line := func(get func(int) bool, set func(int, bool), size int) {
+		// run is the number of points in the current window that are
+		// set when dilating, or that are unset when eroding. Either kind
+		// changes the result from what it would be for an empty window.
+		// Points outside of the mask never count.
+		var run int
+		at := func(i int) bool {
+			if (i < 0) || (i >= size) {
+				return false
+			}
+			return get(i) != erode
+		}
+		for i := -n; i < n; i++ {
+			if at(i) {
+				run++
+			}
+		}
+		for i := range size {
+			if at(i + n) {
+				run++
+			}
+			set(i, (run > 0) != erode)
+			if at(i - n) {
+				run--
+			}
+		}
+	}
+
+	tmp := make([]bool, len(mask))
+	for y := range h {
+		row := mask[y*w : (y+1)*w]
+		line(func(x int) bool { return row[x] }, func(x int, v bool) { tmp[y*w+x] = v }, w)
+	}
+	out := make([]bool, len(mask))
+	for x := range w {
+		line(func(y int) bool { return tmp[y*w+x] }, func(y int, v bool) { out[y*w+x] = v }, h)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ReadSTL reads a mesh from a binary or an ASCII STL file. The normals
+// of the triangles are ignored.
+func ReadSTL(r io.Reader) (*Mesh, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// ASCII files start with "solid", but so do some binary files, so
+	// the size of the data is checked against the number of triangles
+	// that a binary file would have first.
+	if len(data) >= 84 {
+		n := binary.LittleEndian.Uint32(data[80:])
+		if uint64(len(data)) == 84+50*uint64(n) {
+			return readBinarySTL(data[84:], int(n)), nil
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return readASCIISTL(data)
+	}
+	return nil, errors.New("not a valid STL file")
+}
+
+func readBinarySTL(data []byte, n int) *Mesh {
+	coord := func(data []byte) float64 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+
+	m := Mesh{Triangles: make([]Triangle, n)}
+	for i := range m.Triangles {
+		// Each triangle is a normal, three vertices, and a two-byte
+		// attribute count.
+		t := data[50*i+12:]
+		for v := range 3 {
+			m.Triangles[i][v] = Vec{coord(t[12*v:]), coord(t[12*v+4:]), coord(t[12*v+8:])}
+		}
+	}
+	return &m
+}
+
+func readASCIISTL(data []byte) (*Mesh, error) {
+	var m Mesh
+	var t Triangle
+	var n int
+
+	fields := bytes.Fields(data)
+	for i := 0; i < len(fields); i++ {
+		if string(fields[i]) != "vertex" {
+			continue
+		}
+		if i+3 >= len(fields) {
+			return nil, errors.New("vertex has fewer than three coordinates")
+		}
+
+		var v [3]float64
+		for c := range v {
+			f, err := strconv.ParseFloat(string(fields[i+c+1]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("vertex %v: %w", 3*len(m.Triangles)+n+1, err)
+			}
+			v[c] = f
+		}
+		i += 3
+
+		t[n] = Vec{v[0], v[1], v[2]}
+		n++
+		if n == 3 {
+			m.Triangles = append(m.Triangles, t)
+			n = 0
+		}
+	}
+	if n != 0 {
+		return nil, errors.New("number of vertices is not a multiple of three")
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,173 @@
+// Package netpbm implements decoders and encoders for the Netpbm
+// formats that are useful for storing depth maps without losing
+// precision: binary PGM files with 8 or 16 bits per pixel and PFM
+// files, which hold 32-bit floating-point values.
+//
+// Importing this package registers the PGM decoder with the image
+// package so that image.Decode can read PGM files. PFM files hold
+// depths rather than colors, so they are decoded directly into a
+// *sirdsc.Depth with DecodePFM.
+//
+// Files whose headers claim rasters of more than 256 MiB are rejected
+// with ErrFormat, so that untrusted files can't make the decoders
+// allocate huge amounts of memory.
+package netpbm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrFormat is returned when the input is not a valid file of the
+// expected format.
+var ErrFormat = errors.New("netpbm: invalid format")
+
+// maxRasterSize is the largest raster, in bytes, that will be decoded.
+// Files that claim to be larger are rejected before anything is
+// allocated for them.
+const maxRasterSize = 1 << 28
+
+// header is the header of a Netpbm file.
+type header struct {
+	magic  string
+	width  int
+	height int
+
+	// max is the maximum value of a PGM file. For a PFM file, it is
+	// zero and scale is set instead.
+	max   int
+	scale float64
+}
+
+// readHeader reads the header of a PGM or PFM file from r. After it
+// returns, r is positioned at the first byte of the raster.
+func readHeader(r *bufio.Reader) (h header, err error) {
+	h.magic, err = readToken(r)
+	if err != nil {
+		return h, err
+	}
+
+	h.width, err = readInt(r)
+	if err != nil {
+		return h, err
+	}
+	h.height, err = readInt(r)
+	if err != nil {
+		return h, err
+	}
+
+	var sample int
+	switch h.magic {
+	case "P5":
+		h.max, err = readInt(r)
+		if err != nil {
+			return h, err
+		}
+		if (h.max <= 0) || (h.max > 0xffff) {
+			return h, fmt.Errorf("%w: maximum value %v out of range", ErrFormat, h.max)
+		}
+		sample = 1
+		if h.max >= 256 {
+			sample = 2
+		}
+
+	case "Pf", "PF":
+		tok, err := readToken(r)
+		if err != nil {
+			return h, err
+		}
+		h.scale, err = strconv.ParseFloat(tok, 64)
+		if (err != nil) || (h.scale == 0) {
+			return h, fmt.Errorf("%w: bad scale %q", ErrFormat, tok)
+		}
+		sample = 4
+		if h.magic == "PF" {
+			sample = 12
+		}
+
+	default:
+		return h, fmt.Errorf("%w: unsupported magic number %q", ErrFormat, h.magic)
+	}
+
+	if (h.width <= 0) || (h.height <= 0) {
+		return h, fmt.Errorf("%w: bad size %vx%v", ErrFormat, h.width, h.height)
+	}
+	if (h.width > maxRasterSize/sample) || (h.height > maxRasterSize/sample/h.width) {
+		return h, fmt.Errorf("%w: size %vx%v too large", ErrFormat, h.width, h.height)
+	}
+
+	// Exactly one whitespace character separates the header from the
+	// raster.
+	_, err = r.ReadByte()
+	return h, err
+}
+
+// readToken reads a whitespace separated token from r, skipping
+// comments. It doesn't consume the whitespace after the token.
+func readToken(r *bufio.Reader) (string, error) {
+	var tok []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if (err == io.EOF) && (len(tok) > 0) {
+				return string(tok), nil
+			}
+			return "", unexpectedEOF(err)
+		}
+
+		switch {
+		case c == '#':
+			if len(tok) > 0 {
+				r.UnreadByte()
+				return string(tok), nil
+			}
+			_, err := r.ReadBytes('\n')
+			if err != nil {
+				return "", unexpectedEOF(err)
+			}
+
+		case isSpace(c):
+			if len(tok) > 0 {
+				r.UnreadByte()
+				return string(tok), nil
+			}
+
+		default:
+			if len(tok) > 32 {
+				return "", fmt.Errorf("%w: header token too long", ErrFormat)
+			}
+			tok = append(tok, c)
+		}
+	}
+}
+
+func readInt(r *bufio.Reader) (int, error) {
+	tok, err := readToken(r)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(tok, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad number %q", ErrFormat, tok)
+	}
+	return int(v), nil
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package netpbm_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/netpbm"
+)
+
+func TestPGMRoundTrip(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 7, 3))
+	gray16 := image.NewGray16(image.Rect(0, 0, 7, 3))
+	for y := range 3 {
+		for x := range 7 {
+			gray.SetGray(x, y, color.Gray{uint8(x*37 + y)})
+			gray16.SetGray16(x, y, color.Gray16{uint16(x*9001 + y*3)})
+		}
+	}
+
+	for _, img := range []image.Image{gray, gray16} {
+		var buf bytes.Buffer
+		err := netpbm.Encode(&buf, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, name, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != "pgm" {
+			t.Fatalf("format %q", name)
+		}
+		if !reflect.DeepEqual(got, img) {
+			t.Fatalf("%T doesn't match after round trip", img)
+		}
+	}
+}
+
+func TestPGMMax(t *testing.T) {
+	data := []byte("P5\n# A comment.\n2 1 # Another one.\n1000\n\x01\xf4\x03\xe8")
+	img, err := netpbm.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray16, ok := img.(*image.Gray16)
+	if !ok {
+		t.Fatalf("decoded a %T", img)
+	}
+	want := []uint16{500 * 0xffff / 1000, 0xffff}
+	for x, want := range want {
+		if got := gray16.Gray16At(x, 0).Y; got != want {
+			t.Errorf("(%v, 0): %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestPFMRoundTrip(t *testing.T) {
+	d := sirdsc.NewDepth(image.Rect(2, 3, 9, 7))
+	for y := d.Rect.Min.Y; y < d.Rect.Max.Y; y++ {
+		for x := d.Rect.Min.X; x < d.Rect.Max.X; x++ {
+			d.Set(x, y, float64(x)/3-float64(y)*1.25)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := netpbm.EncodePFM(&buf, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := netpbm.DecodePFM(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bounds() != d.Bounds().Sub(d.Bounds().Min) {
+		t.Fatalf("bounds: %v", got.Bounds())
+	}
+	for y := d.Rect.Min.Y; y < d.Rect.Max.Y; y++ {
+		for x := d.Rect.Min.X; x < d.Rect.Max.X; x++ {
+			want := d.AtF(x, y)
+			if v := got.AtF(x-d.Rect.Min.X, y-d.Rect.Min.Y); v != want {
+				t.Fatalf("(%v, %v): %v, want %v", x, y, v, want)
+			}
+		}
+	}
+}
+
+func TestPFMColorBigEndian(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("PF\n1 2\n1.0\n")
+	for _, v := range []float32{1, 5, 2, 0.5, -1, 0.25} {
+		binary.Write(&buf, binary.BigEndian, math.Float32bits(v))
+	}
+
+	d, err := netpbm.DecodePFM(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first row in the file is the bottom row.
+	if got := d.AtF(0, 1); got != 5 {
+		t.Errorf("(0, 1): %v, want 5", got)
+	}
+	if got := d.AtF(0, 0); got != 0.5 {
+		t.Errorf("(0, 0): %v, want 0.5", got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"P6\n1 1\n255\n\x00\x00\x00",
+		"P5\n0 1\n255\n",
+		"P5\n1 1\n70000\n\x00\x00",
+		"P5\n2 2\n255\n\x00",
+		"P5\n4294967296 4294967296\n255\n",
+		"P5\n9223372036854775807 2\n255\n",
+		"P5\n16384 16384\n65535\n",
+	}
+
+	for _, test := range tests {
+		_, err := netpbm.Decode(bytes.NewReader([]byte(test)))
+		if err == nil {
+			t.Errorf("decoded %q without an error", test)
+		}
+	}
+}
+
+func TestDecodePFMTooLarge(t *testing.T) {
+	tests := []string{
+		"Pf\n4294967296 4294967296\n-1.0\n",
+		"PF\n8192 8192\n-1.0\n",
+	}
+
+	for _, test := range tests {
+		_, err := netpbm.DecodePFM(bytes.NewReader([]byte(test)))
+		if !errors.Is(err, netpbm.ErrFormat) {
+			t.Errorf("%q: %v, want %v", test, err, netpbm.ErrFormat)
+		}
+	}
+}
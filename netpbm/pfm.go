@@ -0,0 +1,89 @@
+package netpbm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+// DecodePFM reads a PFM file from r and returns its values as depths.
+// Both grayscale files and color files are supported. As with
+// sirdsc.ImageDepthMap, the depth of a pixel in a color file is the
+// largest of its channels. The magnitude of the file's scale factor is
+// ignored, and the values are returned exactly as they are stored.
+//
+// The rows of a PFM file are stored from the bottom of the image to
+// the top, so the first row of the file is the last row of the
+// returned depth map.
+func DecodePFM(r io.Reader) (*sirdsc.Depth, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := 1
+	switch h.magic {
+	case "Pf":
+	case "PF":
+		channels = 3
+	default:
+		return nil, fmt.Errorf("%w: not a PFM file", ErrFormat)
+	}
+
+	order := binary.ByteOrder(binary.BigEndian)
+	if h.scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	d := sirdsc.NewDepth(image.Rect(0, 0, h.width, h.height))
+	buf := make([]byte, h.width*channels*4)
+	for y := h.height - 1; y >= 0; y-- {
+		_, err := io.ReadFull(br, buf)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		row := d.Pix[d.PixOffset(0, y):]
+		for x := range h.width {
+			px := buf[x*channels*4:]
+			v := math.Float32frombits(order.Uint32(px))
+			for c := 1; c < channels; c++ {
+				v = max(v, math.Float32frombits(order.Uint32(px[c*4:])))
+			}
+			row[x] = v
+		}
+	}
+
+	return d, nil
+}
+
+// EncodePFM writes dm to w as a grayscale PFM file. If dm is a
+// sirdsc.DepthMapF, its fractional depths are written, so a
+// *sirdsc.Depth can be saved and loaded again with DecodePFM without
+// any loss of precision.
+func EncodePFM(w io.Writer, dm sirdsc.DepthMap) error {
+	d, ok := dm.(*sirdsc.Depth)
+	if !ok {
+		d = sirdsc.Materialize(dm)
+	}
+	b := d.Bounds()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Pf\n%v %v\n-1.0\n", b.Dx(), b.Dy())
+
+	buf := make([]byte, b.Dx()*4)
+	for y := b.Max.Y - 1; y >= b.Min.Y; y-- {
+		i := d.PixOffset(b.Min.X, y)
+		for x, v := range d.Pix[i : i+b.Dx()] {
+			binary.LittleEndian.PutUint32(buf[x*4:], math.Float32bits(v))
+		}
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,102 @@
+package netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+func init() {
+	image.RegisterFormat("pgm", "P5", Decode, DecodeConfig)
+}
+
+// Decode reads a binary PGM image from r. If the file's maximum value
+// is less than 256, the returned image is an *image.Gray. Otherwise,
+// it is an *image.Gray16. Values are scaled so that the file's maximum
+// value is white.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.magic != "P5" {
+		return nil, fmt.Errorf("%w: not a binary PGM file", ErrFormat)
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	if h.max < 256 {
+		img := image.NewGray(rect)
+		_, err := io.ReadFull(br, img.Pix)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if h.max != 0xff {
+			for i, v := range img.Pix {
+				img.Pix[i] = uint8(min(int(v), h.max) * 0xff / h.max)
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewGray16(rect)
+	_, err = io.ReadFull(br, img.Pix)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if h.max != 0xffff {
+		for i := 0; i < len(img.Pix); i += 2 {
+			v := min(int(img.Pix[i])<<8|int(img.Pix[i+1]), h.max) * 0xffff / h.max
+			img.Pix[i], img.Pix[i+1] = uint8(v>>8), uint8(v)
+		}
+	}
+	return img, nil
+}
+
+// DecodeConfig returns the color model and dimensions of a binary PGM
+// image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	if h.magic != "P5" {
+		return image.Config{}, fmt.Errorf("%w: not a binary PGM file", ErrFormat)
+	}
+
+	model := color.GrayModel
+	if h.max >= 256 {
+		model = color.Gray16Model
+	}
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+// Encode writes img to w as a binary PGM image. If img is an
+// *image.Gray, 8 bits are written per pixel. Otherwise, img is
+// converted to 16-bit grayscale, so an *image.Gray16 is written
+// without any loss of precision.
+func Encode(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	if img, ok := img.(*image.Gray); ok {
+		fmt.Fprintf(bw, "P5\n%v %v\n255\n", b.Dx(), b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := img.PixOffset(b.Min.X, y)
+			bw.Write(img.Pix[i : i+b.Dx()])
+		}
+		return bw.Flush()
+	}
+
+	fmt.Fprintf(bw, "P5\n%v %v\n65535\n", b.Dx(), b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y
+			bw.WriteByte(uint8(v >> 8))
+			bw.WriteByte(uint8(v))
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,250 @@
+package sirdsc
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// An Algorithm is a method of generating a stereogram.
+type Algorithm int
+
+const (
+	// Copy generates a stereogram by copying each pixel from partSize
+	// pixels to its left and then linking it to the pixel at its
+	// depth. This is the algorithm used by Generate.
+	Copy Algorithm = iota
+
+	// HiddenSurface generates a stereogram by linking together pixels
+	// that must be the same color and dropping links for points that
+	// can only be seen by one eye. This is the algorithm used by
+	// GenerateHSR.
+	HiddenSurface
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case Copy:
+		return "copy"
+	case HiddenSurface:
+		return "hidden surface"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// A ViewingMode is the way that a stereogram is meant to be viewed.
+type ViewingMode int
+
+const (
+	// WallEyed stereograms are viewed by looking through the image, as
+	// though focusing on something behind it. Closer points are
+	// represented by pixels that are closer together.
+	WallEyed ViewingMode = iota
+
+	// CrossEyed stereograms are viewed by crossing the eyes, as though
+	// focusing on something in front of the image. Closer points are
+	// represented by pixels that are further apart.
+	CrossEyed
+)
+
+func (m ViewingMode) String() string {
+	switch m {
+	case WallEyed:
+		return "wall-eyed"
+	case CrossEyed:
+		return "cross-eyed"
+	default:
+		return fmt.Sprintf("ViewingMode(%d)", int(m))
+	}
+}
+
+// A Propagation is a direction that constraints between pixels are
+// propagated in.
+type Propagation int
+
+const (
+	// PropagateRight starts each row with an unmodified strip of the
+	// pattern at its left edge and propagates constraints to the right
+	// from it. Any distortion of the pattern builds up towards the right
+	// side of the stereogram.
+	PropagateRight Propagation = iota
+
+	// PropagateOut starts each row with an unmodified strip of the
+	// pattern at Options.Origin and propagates constraints both to the
+	// left and to the right from it. This spreads distortion out
+	// towards both sides of the stereogram, leaving its center, which
+	// is usually where the viewer is looking, the least distorted.
+	PropagateOut
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagateRight:
+		return "right"
+	case PropagateOut:
+		return "out"
+	default:
+		return fmt.Sprintf("Propagation(%d)", int(p))
+	}
+}
+
+// A Framing is a way of positioning a depth map in the output of a
+// stereogram.
+type Framing int
+
+const (
+	// ExtraStrip makes the output one part wider than the depth map,
+	// with the extra strip on the left, where the stereogram starts.
+	ExtraStrip Framing = iota
+
+	// Cropped makes the output the same size as the depth map.
+	Cropped
+
+	// Centered makes the output one part wider than the depth map,
+	// with half of the extra width on each side.
+	Centered
+)
+
+func (f Framing) String() string {
+	switch f {
+	case ExtraStrip:
+		return "extra strip"
+	case Cropped:
+		return "cropped"
+	case Centered:
+		return "centered"
+	default:
+		return fmt.Sprintf("Framing(%d)", int(f))
+	}
+}
+
+// Options are the options for GenerateContext. The zero value is
+// valid and results in the same behavior as Generate with a partSize
+// of zero.
+type Options struct {
+	// PartSize is the width of a single section of the generated
+	// stereogram. If it is less than or equal to zero, the width of
+	// the pattern is used. PartSize is ignored if Geometry is not nil.
+	PartSize int
+
+	// Geometry, if not nil, is used to convert depths into separations
+	// between pixels. Each depth is divided by MaxDepth to get a
+	// normalized depth, and PartSize is the separation of the far
+	// plane. If Geometry is nil, each depth is simply subtracted from
+	// PartSize.
+	Geometry *ViewingGeometry
+
+	// MaxDepth is the depth that is considered to be on the near plane
+	// when Geometry is not nil. If it is less than or equal to zero,
+	// DefaultMaxImageDepth is used.
+	MaxDepth int
+
+	// Algorithm is the algorithm to generate the stereogram with.
+	Algorithm Algorithm
+
+	// Mode is the way that the stereogram is meant to be viewed. In
+	// CrossEyed mode, near and far are swapped when calculating
+	// separations: Without Geometry, each depth is added to PartSize
+	// instead of being subtracted from it, and with Geometry, a
+	// normalized depth of z is given the separation that a depth of
+	// 1-z would have in WallEyed mode.
+	Mode ViewingMode
+
+	// Workers is the number of goroutines to generate the stereogram
+	// with. The rows of the output are split into bands which are
+	// processed by the workers. If Workers is less than or equal to
+	// zero, runtime.GOMAXPROCS(0) is used. Workers is ignored if Pool
+	// is not nil.
+	Workers int
+
+	// Pool, if not nil, is used to run the workers instead of starting
+	// new goroutines.
+	Pool *Pool
+
+	// Propagation is the direction that constraints are propagated
+	// across each row in.
+	Propagation Propagation
+
+	// Origin is the column that PropagateOut propagates outwards from,
+	// as an offset from the left edge of the frame. The strip of the
+	// pattern that is centered on it is drawn unmodified. If Origin is
+	// zero, the center of the frame is used.
+	Origin int
+
+	// Framing is the way that the depth map is positioned in the
+	// output. See OutputBounds.
+	Framing Framing
+
+	// Oversample is the number of samples to generate for each pixel
+	// of the output. If it is greater than one, each row is generated
+	// at Oversample times the horizontal resolution of the output and
+	// then averaged back down. Depths are interpolated between the
+	// columns of the depth map and separations are not rounded to
+	// whole pixels, so curved surfaces are reproduced smoothly instead
+	// of as a series of steps. If the depth map implements DepthMapF,
+	// its fractional depths are used.
+	Oversample int
+
+	// Frame is the bounds of the complete stereogram. If it is empty,
+	// the bounds of the output image are used.
+	//
+	// If Frame is larger than the output image, only the part of the
+	// stereogram that overlaps the output is drawn, but it is drawn
+	// exactly as it would be if the entire stereogram had been
+	// generated. This makes it possible to render a crop of a larger
+	// stereogram, or to redraw a region of one that has changed by
+	// passing a SubImage of it as the output and its bounds as Frame.
+	Frame image.Rectangle
+}
+
+// OutputBounds returns the bounds of the output image that a
+// stereogram of a depth map with the bounds r needs according to the
+// options' Framing. pat is the pattern that will be used, which
+// determines the width of a part if neither PartSize nor Geometry is
+// set. opts may be nil.
+//
+// With ExtraStrip and Centered framing, the output extends one part
+// further to the right than the depth map. With Cropped framing, it
+// is the same as r.
+func (opts *Options) OutputBounds(r image.Rectangle, pat image.Image) image.Rectangle {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	if opts.Framing == Cropped {
+		return r
+	}
+
+	r.Max.X += opts.partSize(pat)
+	return r
+}
+
+// partSize returns the separation of the background plane.
+func (opts *Options) partSize(pat image.Image) int {
+	if opts.Geometry != nil {
+		far := 0.0
+		if opts.Mode == CrossEyed {
+			far = 1
+		}
+		return int(math.Round(opts.Geometry.Separation(far)))
+	}
+
+	if opts.PartSize <= 0 {
+		return pat.Bounds().Dx()
+	}
+	return opts.PartSize
+}
+
+// depthOffset returns the distance between a column of the output and
+// the column of the depth map that is shown in it.
+func (opts *Options) depthOffset(partSize int) int {
+	switch opts.Framing {
+	case Cropped:
+		return 0
+	case Centered:
+		return partSize / 2
+	default:
+		return partSize
+	}
+}
@@ -0,0 +1,96 @@
+package sirdsc_test
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestOptionsOutputBounds(t *testing.T) {
+	r := image.Rect(10, 20, 110, 70)
+	pat := image.NewGray(image.Rect(0, 0, 30, 30))
+
+	tests := []struct {
+		name string
+		opts *sirdsc.Options
+		want image.Rectangle
+	}{
+		{name: "Nil", opts: nil, want: image.Rect(10, 20, 140, 70)},
+		{name: "ExtraStrip", opts: &sirdsc.Options{PartSize: 20}, want: image.Rect(10, 20, 130, 70)},
+		{name: "Cropped", opts: &sirdsc.Options{PartSize: 20, Framing: sirdsc.Cropped}, want: r},
+		{name: "Centered", opts: &sirdsc.Options{PartSize: 20, Framing: sirdsc.Centered}, want: image.Rect(10, 20, 130, 70)},
+		{name: "Geometry", opts: &sirdsc.Options{Geometry: &sirdsc.ViewingGeometry{}}, want: image.Rect(10, 20, 230, 70)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.opts.OutputBounds(r, pat)
+			if got != test.want {
+				t.Fatalf("OutputBounds(%v) == %v, want %v", r, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePropagateOut(t *testing.T) {
+	const (
+		partSize = 20
+		depth    = 6
+	)
+
+	pat := sirdsc.RandImage{Seed: 1}
+	for _, algorithm := range []sirdsc.Algorithm{sirdsc.Copy, sirdsc.HiddenSurface} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			t.Run("Links", func(t *testing.T) {
+				dm := constDepthMap{rect: image.Rect(0, 0, 200, 10), depth: depth}
+				out := image.NewNRGBA(image.Rect(0, 0, 200, 10))
+				err := sirdsc.GenerateContext(context.Background(), out, dm, pat, &sirdsc.Options{
+					PartSize:    partSize,
+					Algorithm:   algorithm,
+					Propagation: sirdsc.PropagateOut,
+					Framing:     sirdsc.Cropped,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sep := partSize - depth
+				for y := out.Rect.Min.Y; y < out.Rect.Max.Y; y++ {
+					for x := partSize; x+sep < out.Rect.Max.X-partSize; x++ {
+						c1 := out.At(x, y)
+						c2 := out.At(x+sep, y)
+						if c1 != c2 {
+							t.Fatalf("(%v, %v): %#v != %#v", x, y, c1, c2)
+						}
+					}
+				}
+			})
+
+			t.Run("Strip", func(t *testing.T) {
+				dm := constDepthMap{rect: image.Rect(150, 0, 200, 10), depth: depth}
+				out := image.NewNRGBA(image.Rect(0, 0, 200, 10))
+				err := sirdsc.GenerateContext(context.Background(), out, dm, pat, &sirdsc.Options{
+					PartSize:    partSize,
+					Algorithm:   algorithm,
+					Propagation: sirdsc.PropagateOut,
+					Framing:     sirdsc.Cropped,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for y := out.Rect.Min.Y; y < out.Rect.Max.Y; y++ {
+					for x := 90; x < 110; x++ {
+						c1 := out.At(x, y)
+						c2 := out.ColorModel().Convert(pat.At(x-partSize, y))
+						if c1 != c2 {
+							t.Fatalf("(%v, %v): %#v != %#v", x, y, c1, c2)
+						}
+					}
+				}
+			})
+		})
+	}
+}
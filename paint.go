@@ -0,0 +1,358 @@
+package sirdsc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// A painter fills in a row of an output image from a row of labels.
+type painter interface {
+	// paintRow paints the pixels from (x0, y) to
+	// (x0+len(labels)/n-1, y), where n is the number of samples per
+	// pixel that the painter was created with.
+	paintRow(labels []int, x0, y int, s *rowScratch)
+}
+
+// newPainter returns a painter that draws pat to out with n samples
+// per pixel. If out is an *image.NRGBA, *image.RGBA, or
+// *image.Paletted, the returned painter writes directly to its Pix
+// slice, producing the same result as calling out.Set would but
+// without going through color.Color for every pixel.
+func newPainter(out draw.Image, pat image.Image, n int) painter {
+	p := newPixelPainter(out, pat)
+	if n <= 1 {
+		return p
+	}
+
+	put := func(x, y int, c sample) {
+		out.Set(x, y, color.RGBA64{uint16(c.r), uint16(c.g), uint16(c.b), uint16(c.a)})
+	}
+	if p, ok := p.(*pixPainter); ok {
+		put = p.put
+	}
+	return &averagePainter{
+		n:      n,
+		sample: newSampler(pat),
+		put:    put,
+	}
+}
+
+// newPixelPainter returns a painter that draws pat to out with one
+// sample per pixel.
+func newPixelPainter(out draw.Image, pat image.Image) painter {
+	switch out := out.(type) {
+	case *image.NRGBA:
+		return &pixPainter{
+			pix:    out.Pix,
+			stride: out.Stride,
+			rect:   out.Rect,
+			size:   4,
+			encode: encodeNRGBA,
+			sample: newSampler(pat),
+		}
+
+	case *image.RGBA:
+		return &pixPainter{
+			pix:    out.Pix,
+			stride: out.Stride,
+			rect:   out.Rect,
+			size:   4,
+			encode: encodeRGBA,
+			sample: newSampler(pat),
+		}
+
+	case *image.Paletted:
+		if len(out.Palette) == 0 {
+			break
+		}
+		return &pixPainter{
+			pix:    out.Pix,
+			stride: out.Stride,
+			rect:   out.Rect,
+			size:   1,
+			encode: func(dst []uint8, c sample) { dst[0] = uint8(paletteIndex(out.Palette, c)) },
+			sample: newSampler(pat),
+		}
+	}
+
+	return imagePainter{
+		out: out,
+		pat: TiledImage{Image: pat},
+	}
+}
+
+// labelRange returns the smallest label in labels and the number of
+// labels between it and the largest label, inclusive.
+func labelRange(labels []int) (lmin, n int) {
+	if len(labels) == 0 {
+		return 0, 0
+	}
+
+	lmin, lmax := labels[0], labels[0]
+	for _, l := range labels[1:] {
+		lmin = min(lmin, l)
+		lmax = max(lmax, l)
+	}
+	return lmin, lmax - lmin + 1
+}
+
+// imagePainter paints to an arbitrary draw.Image. The colors read
+// from the pattern are cached for each row, so the pattern is only
+// read once per label.
+type imagePainter struct {
+	out draw.Image
+	pat TiledImage
+}
+
+func (p imagePainter) paintRow(labels []int, x0, y int, s *rowScratch) {
+	lmin, n := labelRange(labels)
+	s.colors = resize(s.colors, n)
+	clear(s.colors)
+
+	for i, l := range labels {
+		c := s.colors[l-lmin]
+		if c == nil {
+			c = p.pat.At(l, y)
+			s.colors[l-lmin] = c
+		}
+		p.out.Set(x0+i, y, c)
+	}
+}
+
+// pixPainter paints directly to the Pix slice of an image. The
+// pattern's colors are converted to the image's pixel format once per
+// label in each row and then copied to every pixel with that label.
+type pixPainter struct {
+	pix    []uint8
+	stride int
+	rect   image.Rectangle
+	size   int
+	encode func(dst []uint8, c sample)
+	sample func(x, y int) sample
+}
+
+func (p *pixPainter) paintRow(labels []int, x0, y int, s *rowScratch) {
+	lmin, n := labelRange(labels)
+	s.cache = resize(s.cache, n*p.size)
+	s.filled = resize(s.filled, n)
+	clear(s.filled)
+
+	row := p.pix[(y-p.rect.Min.Y)*p.stride+(x0-p.rect.Min.X)*p.size:]
+	for i, l := range labels {
+		dst := row[i*p.size : (i+1)*p.size]
+		k := l - lmin
+		c := s.cache[k*p.size : (k+1)*p.size]
+		if !s.filled[k] {
+			p.encode(c, p.sample(l, y))
+			s.filled[k] = true
+		}
+		if p.size == 4 {
+			dst[0], dst[1], dst[2], dst[3] = c[0], c[1], c[2], c[3]
+			continue
+		}
+		copy(dst, c)
+	}
+}
+
+// put writes c to the pixel at (x, y).
+func (p *pixPainter) put(x, y int, c sample) {
+	i := (y-p.rect.Min.Y)*p.stride + (x-p.rect.Min.X)*p.size
+	p.encode(p.pix[i:i+p.size], c)
+}
+
+// averagePainter paints rows with more than one sample per pixel. The
+// pattern is stretched horizontally by a factor of n so that labels,
+// which are in samples, map to its pixels, and the color of each pixel
+// is the average of the colors of its samples.
+type averagePainter struct {
+	n      int
+	sample func(x, y int) sample
+	put    func(x, y int, c sample)
+}
+
+func (p *averagePainter) paintRow(labels []int, x0, y int, s *rowScratch) {
+	lmin, n := labelRange(labels)
+	pmin := floorDiv(lmin, p.n)
+	n = floorDiv(lmin+n-1, p.n) - pmin + 1
+	s.samples = resize(s.samples, n)
+	s.filled = resize(s.filled, n)
+	clear(s.filled)
+
+	for i := 0; i < len(labels)/p.n; i++ {
+		var r, g, b, a uint32
+		for _, l := range labels[i*p.n : (i+1)*p.n] {
+			px := floorDiv(l, p.n)
+			k := px - pmin
+			if !s.filled[k] {
+				s.samples[k] = p.sample(px, y)
+				s.filled[k] = true
+			}
+			c := s.samples[k]
+			r, g, b, a = r+c.r, g+c.g, b+c.b, a+c.a
+		}
+
+		m := uint32(p.n)
+		p.put(x0+i, y, sample{r: r / m, g: g / m, b: b / m, a: a / m})
+	}
+}
+
+// floorDiv returns x/y rounded towards negative infinity.
+func floorDiv(x, y int) int {
+	q := x / y
+	if (x%y != 0) && ((x < 0) != (y < 0)) {
+		q--
+	}
+	return q
+}
+
+// A sample is a color read from a pattern. It holds the color in the
+// forms needed to convert it for each of the output types that
+// pixPainter supports in exactly the same way that the image/color
+// package would.
+type sample struct {
+	// r, g, b, and a are the color's alpha-premultiplied values as
+	// returned by its RGBA method.
+	r, g, b, a uint32
+
+	// If isNRGBA is true, the color was a color.NRGBA and n is the
+	// original color.
+	n       color.NRGBA
+	isNRGBA bool
+}
+
+func sampleNRGBA(c color.NRGBA) sample {
+	r, g, b, a := c.RGBA()
+	return sample{r: r, g: g, b: b, a: a, n: c, isNRGBA: true}
+}
+
+func sampleRGBA(c color.RGBA) sample {
+	r, g, b, a := c.RGBA()
+	return sample{r: r, g: g, b: b, a: a}
+}
+
+func sampleColor(c color.Color) sample {
+	if c, ok := c.(color.NRGBA); ok {
+		return sampleNRGBA(c)
+	}
+	r, g, b, a := c.RGBA()
+	return sample{r: r, g: g, b: b, a: a}
+}
+
+// newSampler returns a function that reads colors from pat, tiled in
+// the same way as TiledImage. Patterns of common concrete types are
+// read directly rather than through their At methods.
+func newSampler(pat image.Image) func(x, y int) sample {
+	tiled := TiledImage{Image: pat}
+
+	switch pat := pat.(type) {
+	case *image.NRGBA:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			if !(image.Point{x, y}.In(pat.Rect)) {
+				return sampleNRGBA(color.NRGBA{})
+			}
+			i := pat.PixOffset(x, y)
+			s := pat.Pix[i : i+4 : i+4]
+			return sampleNRGBA(color.NRGBA{s[0], s[1], s[2], s[3]})
+		}
+
+	case *image.RGBA:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			if !(image.Point{x, y}.In(pat.Rect)) {
+				return sampleRGBA(color.RGBA{})
+			}
+			i := pat.PixOffset(x, y)
+			s := pat.Pix[i : i+4 : i+4]
+			return sampleRGBA(color.RGBA{s[0], s[1], s[2], s[3]})
+		}
+
+	case *image.Paletted:
+		if len(pat.Palette) == 0 {
+			break
+		}
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			if !(image.Point{x, y}.In(pat.Rect)) {
+				return sampleColor(pat.Palette[0])
+			}
+			return sampleColor(pat.Palette[pat.Pix[pat.PixOffset(x, y)]])
+		}
+
+	case RandImage:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			return sampleRGBA(pat.rgba(x, y))
+		}
+
+	case *RandImage:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			return sampleRGBA(pat.rgba(x, y))
+		}
+
+	case SymmetricRandImage:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			return sampleRGBA(pat.rgba(x, y))
+		}
+
+	case *SymmetricRandImage:
+		return func(x, y int) sample {
+			x, y = tiled.c(x, y)
+			return sampleRGBA(pat.rgba(x, y))
+		}
+	}
+
+	return func(x, y int) sample {
+		return sampleColor(tiled.At(x, y))
+	}
+}
+
+// encodeNRGBA writes c to dst the way that (*image.NRGBA).Set would.
+func encodeNRGBA(dst []uint8, c sample) {
+	dst = dst[:4:4]
+	switch {
+	case c.isNRGBA:
+		dst[0], dst[1], dst[2], dst[3] = c.n.R, c.n.G, c.n.B, c.n.A
+	case c.a == 0xffff:
+		dst[0], dst[1], dst[2], dst[3] = uint8(c.r>>8), uint8(c.g>>8), uint8(c.b>>8), 0xff
+	case c.a == 0:
+		dst[0], dst[1], dst[2], dst[3] = 0, 0, 0, 0
+	default:
+		r := (c.r * 0xffff) / c.a
+		g := (c.g * 0xffff) / c.a
+		b := (c.b * 0xffff) / c.a
+		dst[0], dst[1], dst[2], dst[3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(c.a>>8)
+	}
+}
+
+// encodeRGBA writes c to dst the way that (*image.RGBA).Set would.
+func encodeRGBA(dst []uint8, c sample) {
+	dst = dst[:4:4]
+	dst[0], dst[1], dst[2], dst[3] = uint8(c.r>>8), uint8(c.g>>8), uint8(c.b>>8), uint8(c.a>>8)
+}
+
+// paletteIndex is equivalent to p.Index, but takes a sample instead of
+// a color.Color.
+func paletteIndex(p color.Palette, c sample) int {
+	ret, bestSum := 0, uint32(1<<32-1)
+	for i, v := range p {
+		vr, vg, vb, va := v.RGBA()
+		sum := sqDiff(c.r, vr) + sqDiff(c.g, vg) + sqDiff(c.b, vb) + sqDiff(c.a, va)
+		if sum < bestSum {
+			if sum == 0 {
+				return i
+			}
+			ret, bestSum = i, sum
+		}
+	}
+	return ret
+}
+
+// sqDiff is copied from image/color.
+func sqDiff(x, y uint32) uint32 {
+	d := x - y
+	return (d * d) >> 2
+}
@@ -0,0 +1,58 @@
+package sirdsc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// A Pool is a fixed set of worker goroutines that generation work can
+// be run on. A Pool can be shared between any number of concurrent
+// calls to GenerateContext, which allows callers that generate
+// stereograms repeatedly, such as a real-time render loop or a server,
+// to avoid starting new goroutines for every image.
+type Pool struct {
+	tasks   chan func()
+	workers int
+	wg      sync.WaitGroup
+}
+
+// NewPool starts a new pool with the given number of workers. If
+// workers is less than or equal to zero, runtime.GOMAXPROCS(0) is
+// used.
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	p := Pool{
+		tasks:   make(chan func()),
+		workers: workers,
+	}
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+
+	return &p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for task := range p.tasks {
+		task()
+	}
+}
+
+// Workers returns the number of workers in the pool.
+func (p *Pool) Workers() int {
+	return p.workers
+}
+
+// Close stops the pool's workers and waits for them to finish any
+// work that they are currently doing. The pool must not be used after
+// it has been closed.
+func (p *Pool) Close() {
+	close(p.tasks)
+	p.wg.Wait()
+}
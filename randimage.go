@@ -26,6 +26,10 @@ func (img RandImage) Bounds() image.Rectangle {
 }
 
 func (img RandImage) At(x, y int) color.Color {
+	return img.rgba(x, y)
+}
+
+func (img RandImage) rgba(x, y int) color.RGBA {
 	c, _, _ := spcg.Next(uint64(x)^img.Seed, uint64(y)^img.Seed)
 
 	return color.RGBA{
@@ -51,6 +55,10 @@ func (img SymmetricRandImage) Bounds() image.Rectangle {
 }
 
 func (img SymmetricRandImage) At(x, y int) color.Color {
+	return img.rgba(x, y)
+}
+
+func (img SymmetricRandImage) rgba(x, y int) color.RGBA {
 	c, _, _ := spcg.Next(uint64(x^y)^img.Seed, uint64(x^y)^img.Seed)
 
 	return color.RGBA{
@@ -0,0 +1,166 @@
+package sirdsc
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// A Filter is a method of interpolating between depths when
+// resampling a depth map.
+type Filter int
+
+const (
+	// NearestNeighbor uses the depth of the nearest point. It is the
+	// fastest filter and keeps sharp edges, but it produces blocky
+	// results when enlarging.
+	NearestNeighbor Filter = iota
+
+	// Bilinear interpolates linearly between the nearest points.
+	Bilinear
+
+	// Bicubic uses the Catmull-Rom cubic filter. It is the slowest
+	// filter, but it produces the smoothest surfaces.
+	Bicubic
+)
+
+func (f Filter) String() string {
+	switch f {
+	case NearestNeighbor:
+		return "nearest"
+	case Bilinear:
+		return "bilinear"
+	case Bicubic:
+		return "bicubic"
+	default:
+		return fmt.Sprintf("Filter(%d)", int(f))
+	}
+}
+
+func (f Filter) interpolator() draw.Interpolator {
+	switch f {
+	case Bilinear:
+		return draw.BiLinear
+	case Bicubic:
+		return draw.CatmullRom
+	default:
+		return draw.NearestNeighbor
+	}
+}
+
+// A ResampleMode is a way of fitting a depth map into a size that has
+// a different aspect ratio.
+type ResampleMode int
+
+const (
+	// Stretch scales the depth map to exactly the requested size,
+	// ignoring its aspect ratio.
+	Stretch ResampleMode = iota
+
+	// Fit scales the depth map, keeping its aspect ratio, to the
+	// largest size that fits inside of the requested size and centers
+	// it. The space around it is filled with a depth of zero.
+	Fit
+
+	// Fill scales the depth map, keeping its aspect ratio, to the
+	// smallest size that covers the requested size and centers it,
+	// cropping whatever doesn't fit.
+	Fill
+)
+
+func (m ResampleMode) String() string {
+	switch m {
+	case Stretch:
+		return "stretch"
+	case Fit:
+		return "fit"
+	case Fill:
+		return "fill"
+	default:
+		return fmt.Sprintf("ResampleMode(%d)", int(m))
+	}
+}
+
+// Resample scales dm to size using filter, fitting it in according to
+// mode. The returned depth map's bounds are from (0, 0) to size. If
+// one of the dimensions of size is zero or negative, it is calculated
+// from the other so that dm's aspect ratio is kept, and mode is
+// irrelevant. If dm's bounds are empty, the dimensions that can't be
+// calculated are zero.
+//
+// The scaling itself is done by golang.org/x/image/draw. Depths are
+// stored in a 16-bit image that covers the range of depths in dm while
+// they are being scaled, so the result is precise to about 1/65535 of
+// that range.
+func Resample(dm DepthMap, size image.Point, filter Filter, mode ResampleMode) *Depth {
+	size = image.Pt(max(size.X, 0), max(size.Y, 0))
+	sr := dm.Bounds()
+	if sr.Empty() {
+		return NewDepth(image.Rectangle{Max: size})
+	}
+
+	switch {
+	case (size.X <= 0) && (size.Y <= 0):
+		size = sr.Size()
+	case size.X <= 0:
+		size.X = max(int(math.Round(float64(sr.Dx()*size.Y)/float64(sr.Dy()))), 1)
+	case size.Y <= 0:
+		size.Y = max(int(math.Round(float64(sr.Dy()*size.X)/float64(sr.Dx()))), 1)
+	}
+
+	src := Materialize(dm)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range src.Pix {
+		lo = min(lo, float64(v))
+		hi = max(hi, float64(v))
+	}
+	scale := hi - lo
+	if scale == 0 {
+		scale = 1
+	}
+
+	img := image.NewGray16(sr)
+	for i, v := range src.Pix {
+		n := uint16(math.Round((float64(v) - lo) / scale * math.MaxUint16))
+		img.Pix[2*i], img.Pix[2*i+1] = uint8(n>>8), uint8(n)
+	}
+
+	out := image.Rectangle{Max: size}
+	dr := resampleRect(sr.Size(), size, mode)
+	scaled := image.NewGray16(out)
+	filter.interpolator().Scale(scaled, dr, img, sr, draw.Src, nil)
+
+	d := NewDepth(out)
+	area := dr.Intersect(out)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			v := scaled.Gray16At(x, y).Y
+			d.Pix[d.PixOffset(x, y)] = float32(lo + float64(v)/math.MaxUint16*scale)
+		}
+	}
+	return d
+}
+
+// resampleRect returns the rectangle that a depth map of size src is
+// scaled into when resampling it to size according to mode.
+func resampleRect(src, size image.Point, mode ResampleMode) image.Rectangle {
+	sx := float64(size.X) / float64(src.X)
+	sy := float64(size.Y) / float64(src.Y)
+
+	var s float64
+	switch mode {
+	case Fit:
+		s = min(sx, sy)
+	case Fill:
+		s = max(sx, sy)
+	default:
+		return image.Rectangle{Max: size}
+	}
+
+	w := int(math.Round(float64(src.X) * s))
+	h := int(math.Round(float64(src.Y) * s))
+	min := image.Pt((size.X-w)/2, (size.Y-h)/2)
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
@@ -0,0 +1,103 @@
+package sirdsc_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestResample(t *testing.T) {
+	src := sirdsc.NewDepth(image.Rect(3, 3, 5, 4))
+	src.Set(3, 3, 10)
+	src.Set(4, 3, 20)
+
+	tests := []struct {
+		name   string
+		size   image.Point
+		filter sirdsc.Filter
+		mode   sirdsc.ResampleMode
+		bounds image.Rectangle
+		want   [][]float64
+	}{
+		{
+			name:   "Stretch",
+			size:   image.Pt(4, 2),
+			bounds: image.Rect(0, 0, 4, 2),
+			want: [][]float64{
+				{10, 10, 20, 20},
+				{10, 10, 20, 20},
+			},
+		},
+		{
+			name:   "Fit",
+			size:   image.Pt(4, 4),
+			mode:   sirdsc.Fit,
+			bounds: image.Rect(0, 0, 4, 4),
+			want: [][]float64{
+				{0, 0, 0, 0},
+				{10, 10, 20, 20},
+				{10, 10, 20, 20},
+				{0, 0, 0, 0},
+			},
+		},
+		{
+			name:   "Fill",
+			size:   image.Pt(2, 2),
+			mode:   sirdsc.Fill,
+			bounds: image.Rect(0, 0, 2, 2),
+			want: [][]float64{
+				{10, 20},
+				{10, 20},
+			},
+		},
+		{
+			name:   "Height",
+			size:   image.Pt(6, 0),
+			bounds: image.Rect(0, 0, 6, 3),
+			want: [][]float64{
+				{10, 10, 10, 20, 20, 20},
+			},
+		},
+		{
+			name:   "Bilinear",
+			size:   image.Pt(4, 1),
+			filter: sirdsc.Bilinear,
+			bounds: image.Rect(0, 0, 4, 1),
+			want: [][]float64{
+				{10, 12.5, 17.5, 20},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := sirdsc.Resample(src, test.size, test.filter, test.mode)
+			if d.Bounds() != test.bounds {
+				t.Fatalf("bounds: %v, want %v", d.Bounds(), test.bounds)
+			}
+			for y, row := range test.want {
+				for x, want := range row {
+					if got := d.AtF(x, y); math.Abs(got-want) > 1e-3 {
+						t.Errorf("AtF(%v, %v) == %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResampleEmpty(t *testing.T) {
+	tests := map[image.Point]image.Rectangle{
+		{4, 3}:   image.Rect(0, 0, 4, 3),
+		{-4, 3}:  image.Rect(0, 0, 0, 3),
+		{-1, -1}: image.Rectangle{},
+	}
+	for size, want := range tests {
+		d := sirdsc.Resample(sirdsc.NewDepth(image.Rectangle{}), size, sirdsc.Bilinear, sirdsc.Fit)
+		if d.Bounds() != want {
+			t.Errorf("%v: bounds %v, want %v", size, d.Bounds(), want)
+		}
+	}
+}
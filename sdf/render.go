@@ -0,0 +1,56 @@
+package sdf
+
+import (
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/mesh"
+)
+
+// maxSteps is the largest number of steps that a ray is marched before
+// it is considered to have missed.
+const maxSteps = 256
+
+// Render ray marches s through cam into dst, which is entirely
+// overwritten, in the same way that mesh.Render renders a mesh. If cam
+// fits itself to the scene, it uses the bounds of s. Points where
+// nothing is hit have a depth of zero. If cam is nil, the zero Camera
+// is used.
+func Render(dst *sirdsc.Depth, s Shape, cam *mesh.Camera, depth float64) {
+	r := dst.Rect
+	lo, hi := s.Bounds()
+	rays := cam.Rays(lo, hi, r, depth)
+
+	// A ray has hit the surface once it's closer than eps to it, which
+	// is small relative to the size of the scene.
+	eps := max(hi.Sub(lo).Len()*1e-5, 1e-9)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			origin, dir, length := rays.Ray(float64(x)+0.5, float64(y)+0.5)
+			t, ok := march(s, origin, dir, length, eps)
+
+			row[x-r.Min.X] = 0
+			if ok {
+				row[x-r.Min.X] = float32(rays.Depth(origin.Add(dir.Mul(t))))
+			}
+		}
+	}
+}
+
+// march marches along the ray from origin in the direction dir until it
+// hits s or has gone length. It returns the distance to the hit, if
+// there is one. A ray that starts inside of s hits it immediately.
+func march(s Shape, origin, dir Vec, length, eps float64) (t float64, ok bool) {
+	for range maxSteps {
+		d := s.Dist(origin.Add(dir.Mul(t)))
+		if d < eps {
+			return t, true
+		}
+
+		t += d
+		if t > length {
+			return 0, false
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,226 @@
+package sdf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/DeedleFake/sirdsc/mesh"
+)
+
+// Scene is a shape and the camera to view it through.
+type Scene struct {
+	Shape  Shape
+	Camera mesh.Camera
+}
+
+// ReadScene reads a scene from a JSON document such as
+//
+//	{
+//		"camera": {"rotate": [20, 30, 0], "perspective": true},
+//		"shape": {
+//			"subtract": [
+//				{"box": [2, 2, 2], "round": 0.1},
+//				{"sphere": 1.25}
+//			],
+//			"smooth": 0.1
+//		}
+//	}
+//
+// The shape is a node that is exactly one of
+//
+//	{"sphere": radius}
+//	{"box": [width, height, depth], "round": radius}
+//	{"capsule": {"from": [x, y, z], "to": [x, y, z], "radius": radius}}
+//	{"torus": {"radius": radius, "thickness": radius}}
+//	{"union": [nodes...], "smooth": distance}
+//	{"intersection": [nodes...], "smooth": distance}
+//	{"subtract": [node, nodes...], "smooth": distance}
+//
+// where "round" and "smooth" are optional. Any node can also have a
+// "scale", a "rotate" made of angles in degrees around the X, Y, and Z
+// axes, and a "translate", which are applied in that order as with
+// NewTransform.
+//
+// The camera is optional, and all of its fields are, too. They are
+// "rotate", "spin", "axis", "zoom", "perspective", "fov", "target", and
+// "radius", which set the fields of mesh.Camera with the same names,
+// except that the angles are in degrees.
+func ReadScene(r io.Reader) (*Scene, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var s jsonScene
+	err := d.Decode(&s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Shape == nil {
+		return nil, errors.New("no shape")
+	}
+
+	shape, err := s.Shape.shape("shape")
+	if err != nil {
+		return nil, err
+	}
+	scene := Scene{Shape: shape}
+	if c := s.Camera; c != nil {
+		scene.Camera = mesh.Camera{
+			Rotation: radians(c.Rotate),
+			Spin:     c.Spin * math.Pi / 180,
+			Axis:     vec3(c.Axis),
+			Zoom:     c.Zoom,
+			FOV:      c.FOV * math.Pi / 180,
+			Target:   vec3(c.Target),
+			Radius:   c.Radius,
+		}
+		if c.Perspective {
+			scene.Camera.Projection = mesh.Perspective
+		}
+	}
+	return &scene, nil
+}
+
+type jsonScene struct {
+	Camera *jsonCamera `json:"camera"`
+	Shape  *jsonNode   `json:"shape"`
+}
+
+type jsonCamera struct {
+	Rotate      [3]float64 `json:"rotate"`
+	Spin        float64    `json:"spin"`
+	Axis        [3]float64 `json:"axis"`
+	Zoom        float64    `json:"zoom"`
+	Perspective bool       `json:"perspective"`
+	FOV         float64    `json:"fov"`
+	Target      [3]float64 `json:"target"`
+	Radius      float64    `json:"radius"`
+}
+
+type jsonNode struct {
+	Sphere       *float64     `json:"sphere"`
+	Box          *[3]float64  `json:"box"`
+	Capsule      *jsonCapsule `json:"capsule"`
+	Torus        *jsonTorus   `json:"torus"`
+	Union        []*jsonNode  `json:"union"`
+	Intersection []*jsonNode  `json:"intersection"`
+	Subtract     []*jsonNode  `json:"subtract"`
+
+	Round  float64 `json:"round"`
+	Smooth float64 `json:"smooth"`
+
+	Scale     float64     `json:"scale"`
+	Rotate    *[3]float64 `json:"rotate"`
+	Translate *[3]float64 `json:"translate"`
+}
+
+type jsonCapsule struct {
+	From   [3]float64 `json:"from"`
+	To     [3]float64 `json:"to"`
+	Radius float64    `json:"radius"`
+}
+
+type jsonTorus struct {
+	Radius    float64 `json:"radius"`
+	Thickness float64 `json:"thickness"`
+}
+
+// shape converts n into a Shape. path is the location of n in the
+// document, for errors.
+func (n *jsonNode) shape(path string) (Shape, error) {
+	if n == nil {
+		return nil, fmt.Errorf("%v: null shape", path)
+	}
+
+	var kinds []string
+	var s Shape
+	var err error
+	if n.Sphere != nil {
+		kinds = append(kinds, "sphere")
+		s = Sphere{Radius: *n.Sphere}
+	}
+	if n.Box != nil {
+		kinds = append(kinds, "box")
+		s = Box{Size: vec3(*n.Box), Round: n.Round}
+	}
+	if n.Capsule != nil {
+		kinds = append(kinds, "capsule")
+		s = Capsule{From: vec3(n.Capsule.From), To: vec3(n.Capsule.To), Radius: n.Capsule.Radius}
+	}
+	if n.Torus != nil {
+		kinds = append(kinds, "torus")
+		s = Torus{Radius: n.Torus.Radius, Thickness: n.Torus.Thickness}
+	}
+	if n.Union != nil {
+		kinds = append(kinds, "union")
+		var shapes []Shape
+		shapes, err = children(path+".union", n.Union, 1)
+		s = Union{Shapes: shapes, Smooth: n.Smooth}
+	}
+	if n.Intersection != nil {
+		kinds = append(kinds, "intersection")
+		var shapes []Shape
+		shapes, err = children(path+".intersection", n.Intersection, 1)
+		s = Intersection{Shapes: shapes, Smooth: n.Smooth}
+	}
+	if n.Subtract != nil {
+		kinds = append(kinds, "subtract")
+		var shapes []Shape
+		shapes, err = children(path+".subtract", n.Subtract, 2)
+		if err == nil {
+			s = Subtraction{Shape: shapes[0], Shapes: shapes[1:], Smooth: n.Smooth}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(kinds) {
+	case 0:
+		return nil, fmt.Errorf("%v: no shape", path)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%v: more than one shape: %q", path, kinds)
+	}
+
+	if (n.Scale != 0) || (n.Rotate != nil) || (n.Translate != nil) {
+		var rotate, translate [3]float64
+		if n.Rotate != nil {
+			rotate = *n.Rotate
+		}
+		if n.Translate != nil {
+			translate = *n.Translate
+		}
+		s = NewTransform(s, vec3(translate), radians(rotate), n.Scale)
+	}
+	return s, nil
+}
+
+// children converts the nodes of an operation, which must have at
+// least n of them, into Shapes.
+func children(path string, nodes []*jsonNode, n int) ([]Shape, error) {
+	if len(nodes) < n {
+		return nil, fmt.Errorf("%v: fewer than %v shapes", path, n)
+	}
+
+	shapes := make([]Shape, len(nodes))
+	for i, node := range nodes {
+		s, err := node.shape(fmt.Sprintf("%v[%v]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		shapes[i] = s
+	}
+	return shapes, nil
+}
+
+func vec3(v [3]float64) Vec {
+	return vec(v[0], v[1], v[2])
+}
+
+// radians converts angles in degrees to a Vec of angles in radians.
+func radians(v [3]float64) Vec {
+	return vec3(v).Mul(math.Pi / 180)
+}
@@ -0,0 +1,299 @@
+// Package sdf renders scenes made of signed distance functions into
+// depth maps. Scenes are built from simple shapes that are combined
+// with constructive solid geometry operations, which can blend them
+// smoothly together, and that can be moved, rotated, and scaled. They
+// can be built in Go or read from JSON files with ReadScene, and are
+// ray marched through a mesh.Camera, so they can be viewed in the same
+// ways as meshes.
+package sdf
+
+import (
+	"math"
+
+	"github.com/DeedleFake/sirdsc/mesh"
+)
+
+// Vec is a point or direction in three dimensions. The Y axis points
+// up, and the Z axis points towards the viewer.
+type Vec = mesh.Vec
+
+// Shape is a solid described by a signed distance function.
+type Shape interface {
+	// Dist returns the distance from p to the surface of the shape,
+	// which is negative if p is inside of it. It may underestimate the
+	// distance, but it must never overestimate it.
+	Dist(p Vec) float64
+
+	// Bounds returns the corners of a box, aligned with the axes, that
+	// contains the whole shape.
+	Bounds() (lo, hi Vec)
+}
+
+// Sphere is a sphere centered on the origin.
+type Sphere struct {
+	Radius float64
+}
+
+func (s Sphere) Dist(p Vec) float64 { // nolint
+	return p.Len() - s.Radius
+}
+
+func (s Sphere) Bounds() (lo, hi Vec) { // nolint
+	return vec(-s.Radius, -s.Radius, -s.Radius), vec(s.Radius, s.Radius, s.Radius)
+}
+
+// Box is a box centered on the origin.
+type Box struct {
+	// Size is the width, height, and depth of the box.
+	Size Vec
+
+	// Round is the radius that the edges and corners of the box are
+	// rounded off with.
+	Round float64
+}
+
+func (b Box) Dist(p Vec) float64 { // nolint
+	q := vec(
+		math.Abs(p.X)-b.Size.X/2+b.Round,
+		math.Abs(p.Y)-b.Size.Y/2+b.Round,
+		math.Abs(p.Z)-b.Size.Z/2+b.Round,
+	)
+	outside := vec(max(q.X, 0), max(q.Y, 0), max(q.Z, 0)).Len()
+	inside := min(max(q.X, q.Y, q.Z), 0)
+	return outside + inside - b.Round
+}
+
+func (b Box) Bounds() (lo, hi Vec) { // nolint
+	return b.Size.Mul(-0.5), b.Size.Mul(0.5)
+}
+
+// Capsule is a line segment from From to To with a thickness of
+// Radius around it, so that its ends are rounded.
+type Capsule struct {
+	From, To Vec
+	Radius   float64
+}
+
+func (c Capsule) Dist(p Vec) float64 { // nolint
+	pa, ba := p.Sub(c.From), c.To.Sub(c.From)
+	var h float64
+	if l := dot(ba, ba); l > 0 {
+		h = min(max(dot(pa, ba)/l, 0), 1)
+	}
+	return pa.Sub(ba.Mul(h)).Len() - c.Radius
+}
+
+func (c Capsule) Bounds() (lo, hi Vec) { // nolint
+	r := vec(c.Radius, c.Radius, c.Radius)
+	lo = vec(min(c.From.X, c.To.X), min(c.From.Y, c.To.Y), min(c.From.Z, c.To.Z))
+	hi = vec(max(c.From.X, c.To.X), max(c.From.Y, c.To.Y), max(c.From.Z, c.To.Z))
+	return lo.Sub(r), hi.Add(r)
+}
+
+// Torus is a ring centered on the origin around the Z axis, so that it
+// faces the viewer.
+type Torus struct {
+	// Radius is the distance from the center of the ring to the middle
+	// of its tube.
+	Radius float64
+
+	// Thickness is the radius of the tube.
+	Thickness float64
+}
+
+func (t Torus) Dist(p Vec) float64 { // nolint
+	return math.Hypot(math.Hypot(p.X, p.Y)-t.Radius, p.Z) - t.Thickness
+}
+
+func (t Torus) Bounds() (lo, hi Vec) { // nolint
+	r := t.Radius + t.Thickness
+	return vec(-r, -r, -t.Thickness), vec(r, r, t.Thickness)
+}
+
+// Union is the space inside of any of Shapes.
+type Union struct {
+	Shapes []Shape
+
+	// Smooth is the distance over which the shapes are blended
+	// together. If it is zero, they are joined with sharp creases.
+	Smooth float64
+}
+
+func (u Union) Dist(p Vec) float64 { // nolint
+	d := math.Inf(1)
+	for i, s := range u.Shapes {
+		if i == 0 {
+			d = s.Dist(p)
+			continue
+		}
+		d = smoothMin(d, s.Dist(p), u.Smooth)
+	}
+	return d
+}
+
+func (u Union) Bounds() (lo, hi Vec) { // nolint
+	for i, s := range u.Shapes {
+		slo, shi := s.Bounds()
+		if i == 0 {
+			lo, hi = slo, shi
+			continue
+		}
+		lo = vec(min(lo.X, slo.X), min(lo.Y, slo.Y), min(lo.Z, slo.Z))
+		hi = vec(max(hi.X, shi.X), max(hi.Y, shi.Y), max(hi.Z, shi.Z))
+	}
+
+	// Blending the shapes together can make the union bulge out by up
+	// to a quarter of the blending distance.
+	m := vec(u.Smooth, u.Smooth, u.Smooth).Mul(0.25)
+	return lo.Sub(m), hi.Add(m)
+}
+
+// Intersection is the space inside of all of Shapes.
+type Intersection struct {
+	Shapes []Shape
+
+	// Smooth is the distance over which the shapes are blended
+	// together. If it is zero, they meet with sharp edges.
+	Smooth float64
+}
+
+func (in Intersection) Dist(p Vec) float64 { // nolint
+	d := math.Inf(1)
+	for i, s := range in.Shapes {
+		if i == 0 {
+			d = s.Dist(p)
+			continue
+		}
+		d = -smoothMin(-d, -s.Dist(p), in.Smooth)
+	}
+	return d
+}
+
+func (in Intersection) Bounds() (lo, hi Vec) { // nolint
+	for i, s := range in.Shapes {
+		slo, shi := s.Bounds()
+		if i == 0 {
+			lo, hi = slo, shi
+			continue
+		}
+		lo = vec(max(lo.X, slo.X), max(lo.Y, slo.Y), max(lo.Z, slo.Z))
+		hi = vec(min(hi.X, shi.X), min(hi.Y, shi.Y), min(hi.Z, shi.Z))
+	}
+
+	// If the boxes don't overlap, the intersection is empty.
+	hi = vec(max(lo.X, hi.X), max(lo.Y, hi.Y), max(lo.Z, hi.Z))
+	return lo, hi
+}
+
+// Subtraction is the space inside of Shape but outside of all of
+// Shapes.
+type Subtraction struct {
+	Shape  Shape
+	Shapes []Shape
+
+	// Smooth is the distance over which the cuts are blended into
+	// Shape. If it is zero, they have sharp edges.
+	Smooth float64
+}
+
+func (s Subtraction) Dist(p Vec) float64 { // nolint
+	d := s.Shape.Dist(p)
+	for _, cut := range s.Shapes {
+		d = -smoothMin(-d, cut.Dist(p), s.Smooth)
+	}
+	return d
+}
+
+func (s Subtraction) Bounds() (lo, hi Vec) { // nolint
+	return s.Shape.Bounds()
+}
+
+// smoothMin returns the minimum of a and b, blended smoothly over a
+// distance of k with a polynomial. If k is zero, it is min(a, b).
+func smoothMin(a, b, k float64) float64 {
+	if k <= 0 {
+		return min(a, b)
+	}
+	h := min(max(0.5+0.5*(b-a)/k, 0), 1)
+	return b + (a-b)*h - k*h*(1-h)
+}
+
+// Transform is a shape that has been scaled, rotated, and moved.
+type Transform struct {
+	shape     Shape
+	rot       [3][3]float64
+	translate Vec
+	scale     float64
+}
+
+// NewTransform returns shape scaled by scale, then rotated by rotate,
+// and then moved by translate. rotate is made of angles in radians that
+// the shape is rotated by around the X, Y, and Z axes, in that order,
+// as with mesh.Rotation. If scale is zero, it is 1.
+func NewTransform(shape Shape, translate, rotate Vec, scale float64) *Transform {
+	if scale == 0 {
+		scale = 1
+	}
+	return &Transform{
+		shape:     shape,
+		rot:       mesh.Rotation(rotate),
+		translate: translate,
+		scale:     scale,
+	}
+}
+
+func (t *Transform) Dist(p Vec) float64 { // nolint
+	// The point is transformed into the shape's space by doing the
+	// opposite of each step in reverse. The inverse of a rotation is
+	// its transpose.
+	p = p.Sub(t.translate)
+	r := &t.rot
+	p = vec(
+		r[0][0]*p.X+r[1][0]*p.Y+r[2][0]*p.Z,
+		r[0][1]*p.X+r[1][1]*p.Y+r[2][1]*p.Z,
+		r[0][2]*p.X+r[1][2]*p.Y+r[2][2]*p.Z,
+	)
+	s := math.Abs(t.scale)
+	return t.shape.Dist(p.Mul(1/t.scale)) * s
+}
+
+func (t *Transform) Bounds() (lo, hi Vec) { // nolint
+	slo, shi := t.shape.Bounds()
+	for i := range 8 {
+		c := slo
+		if i&1 != 0 {
+			c.X = shi.X
+		}
+		if i&2 != 0 {
+			c.Y = shi.Y
+		}
+		if i&4 != 0 {
+			c.Z = shi.Z
+		}
+
+		c = c.Mul(t.scale)
+		r := &t.rot
+		c = vec(
+			r[0][0]*c.X+r[0][1]*c.Y+r[0][2]*c.Z,
+			r[1][0]*c.X+r[1][1]*c.Y+r[1][2]*c.Z,
+			r[2][0]*c.X+r[2][1]*c.Y+r[2][2]*c.Z,
+		).Add(t.translate)
+
+		if i == 0 {
+			lo, hi = c, c
+			continue
+		}
+		lo = vec(min(lo.X, c.X), min(lo.Y, c.Y), min(lo.Z, c.Z))
+		hi = vec(max(hi.X, c.X), max(hi.Y, c.Y), max(hi.Z, c.Z))
+	}
+	return lo, hi
+}
+
+// vec returns the Vec (x, y, z).
+func vec(x, y, z float64) Vec {
+	return Vec{X: x, Y: y, Z: z}
+}
+
+func dot(a, b Vec) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
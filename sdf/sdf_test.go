@@ -0,0 +1,158 @@
+package sdf_test
+
+import (
+	"image"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"github.com/DeedleFake/sirdsc/mesh"
+	"github.com/DeedleFake/sirdsc/sdf"
+)
+
+func TestShapes(t *testing.T) {
+	box := sdf.Box{Size: sdf.Vec{X: 2, Y: 4, Z: 6}}
+	tests := []struct {
+		name  string
+		shape sdf.Shape
+		p     sdf.Vec
+		want  float64
+	}{
+		{"Sphere", sdf.Sphere{Radius: 2}, sdf.Vec{X: 3, Y: 4}, 3},
+		{"SphereInside", sdf.Sphere{Radius: 2}, sdf.Vec{}, -2},
+		{"Box", box, sdf.Vec{X: 4}, 3},
+		{"BoxCorner", box, sdf.Vec{X: 4, Y: 6, Z: 3}, 5},
+		{"BoxInside", box, sdf.Vec{Z: 2.5}, -0.5},
+		{"RoundBoxCorner", sdf.Box{Size: sdf.Vec{X: 2, Y: 2, Z: 2}, Round: 0.5}, sdf.Vec{X: 2, Y: 2, Z: 2}, math.Sqrt(3*1.5*1.5) - 0.5},
+		{"Capsule", sdf.Capsule{To: sdf.Vec{Y: 4}, Radius: 1}, sdf.Vec{X: 3, Y: 2}, 2},
+		{"CapsuleEnd", sdf.Capsule{To: sdf.Vec{Y: 4}, Radius: 1}, sdf.Vec{Y: 7}, 2},
+		{"Torus", sdf.Torus{Radius: 3, Thickness: 1}, sdf.Vec{X: 3, Z: 2}, 1},
+		{"TorusHole", sdf.Torus{Radius: 3, Thickness: 1}, sdf.Vec{}, 2},
+		{"Union", sdf.Union{Shapes: []sdf.Shape{sdf.Sphere{Radius: 1}, box}}, sdf.Vec{X: 4}, 3},
+		{"Intersection", sdf.Intersection{Shapes: []sdf.Shape{sdf.Sphere{Radius: 1}, box}}, sdf.Vec{Z: 2}, 1},
+		{"Subtraction", sdf.Subtraction{Shape: box, Shapes: []sdf.Shape{sdf.Sphere{Radius: 2}}}, sdf.Vec{}, 2},
+		{"SmoothUnion", sdf.Union{Shapes: []sdf.Shape{sdf.Sphere{Radius: 1}, sdf.Sphere{Radius: 1}}, Smooth: 1}, sdf.Vec{}, -1.25},
+		{"Transform", sdf.NewTransform(box, sdf.Vec{X: 10}, sdf.Vec{Z: math.Pi / 2}, 2), sdf.Vec{X: 10, Y: 5}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.shape.Dist(test.p); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("distance %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// A box that is rotated a quarter turn and scaled has the bounds of
+	// the box with its width and height swapped.
+	lo, hi := sdf.NewTransform(box, sdf.Vec{X: 10}, sdf.Vec{Z: math.Pi / 2}, 2).Bounds()
+	want := [2]sdf.Vec{{X: 6, Y: -2, Z: -6}, {X: 14, Y: 2, Z: 6}}
+	for i, got := range [2]sdf.Vec{lo, hi} {
+		if got.Sub(want[i]).Len() > 1e-9 {
+			t.Errorf("bounds %v to %v, want %v to %v", lo, hi, want[0], want[1])
+			break
+		}
+	}
+}
+
+func TestReadScene(t *testing.T) {
+	const scene = `{
+	"camera": {"rotate": [0, 90, 0], "perspective": true, "fov": 60, "radius": 5},
+	"shape": {
+		"subtract": [
+			{"box": [2, 2, 2], "round": 0.1},
+			{"sphere": 1, "translate": [1, 0, 0]},
+			{"capsule": {"from": [0, -1, 0], "to": [0, 1, 0], "radius": 0.1}, "scale": 2}
+		],
+		"smooth": 0.1
+	}
+}`
+
+	s, err := sdf.ReadScene(strings.NewReader(scene))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := mesh.Camera{
+		Projection: mesh.Perspective,
+		Rotation:   mesh.Vec{Y: math.Pi / 2},
+		Radius:     5,
+	}
+	if math.Abs(s.Camera.FOV-math.Pi/3) > 1e-9 {
+		t.Errorf("FOV %v, want %v", s.Camera.FOV, math.Pi/3)
+	}
+	want.FOV = s.Camera.FOV
+	if s.Camera != want {
+		t.Errorf("camera %+v, want %+v", s.Camera, want)
+	}
+
+	sub, ok := s.Shape.(sdf.Subtraction)
+	if !ok {
+		t.Fatalf("shape is a %T", s.Shape)
+	}
+	if (len(sub.Shapes) != 2) || (sub.Smooth != 0.1) {
+		t.Fatalf("subtraction %+v", sub)
+	}
+	if got := sub.Shapes[0].Dist(sdf.Vec{X: 1, Y: 3}); math.Abs(got-2) > 1e-9 {
+		t.Errorf("translated sphere distance %v, want 2", got)
+	}
+	if got := sub.Shapes[1].Dist(sdf.Vec{X: 1, Y: 1}); math.Abs(got-0.8) > 1e-9 {
+		t.Errorf("scaled capsule distance %v, want 0.8", got)
+	}
+
+	bad := map[string]string{
+		`{}`:                                   "no shape",
+		`{"shape": {}}`:                        "shape: no shape",
+		`{"shape": {"sphere": 1, "box": [1]}}`: `shape: more than one shape: ["sphere" "box"]`,
+		`{"shape": {"union": [{"sphere": 1}, {"cone": 1}]}}`: `json: unknown field "cone"`,
+		`{"shape": {"union": [{"sphere": 1}, {}]}}`:          "shape.union[1]: no shape",
+		`{"shape": {"subtract": [{"sphere": 1}]}}`:           "shape.subtract: fewer than 2 shapes",
+	}
+	for doc, want := range bad {
+		_, err := sdf.ReadScene(strings.NewReader(doc))
+		if (err == nil) || (err.Error() != want) {
+			t.Errorf("%v: %v, want %v", doc, err, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	// The sphere's bounds are a cube, so the camera frames the sphere
+	// around the cube, whose radius is √3. The front of the sphere is
+	// 1 in front of the center of that sphere.
+	d := sirdsc.NewDepth(image.Rect(0, 0, 100, 100))
+	sdf.Render(d, sdf.Sphere{Radius: 1}, nil, 30)
+
+	want := (1 + math.Sqrt(3)) / (2 * math.Sqrt(3)) * 30
+	if got := d.AtF(50, 50); math.Abs(got-want) > 0.01 {
+		t.Errorf("center: %v, want %v", got, want)
+	}
+
+	// The sphere fits in a circle of radius 50/√3 around the center.
+	for _, p := range []image.Point{{50, 50 - 27}, {50 + 27, 50}, {30, 30}} {
+		if d.AtF(p.X, p.Y) == 0 {
+			t.Errorf("%v: missed", p)
+		}
+	}
+	for _, p := range []image.Point{{50, 50 - 30}, {50 + 30, 50}, {0, 0}, {25, 25}} {
+		if got := d.AtF(p.X, p.Y); got != 0 {
+			t.Errorf("%v: %v, want 0", p, got)
+		}
+	}
+
+	// The renders of a mesh and of a scene through the same camera have
+	// the same depths.
+	cam := &mesh.Camera{Rotation: mesh.Vec{X: -0.4}, Target: mesh.Vec{}, Radius: 2}
+	box := sdf.Box{Size: sdf.Vec{X: 2, Y: 2, Z: 0.001}}
+	sdf.Render(d, box, cam, 30)
+	square := sirdsc.NewDepth(d.Rect)
+	mesh.Render(square, &mesh.Mesh{Triangles: []mesh.Triangle{
+		{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}},
+		{{X: -1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}},
+	}}, cam, 30)
+	for _, p := range []image.Point{{50, 50}, {40, 35}, {60, 68}} {
+		if got, want := d.AtF(p.X, p.Y), square.AtF(p.X, p.Y); math.Abs(got-want) > 0.05 {
+			t.Errorf("%v: %v, mesh has %v", p, got, want)
+		}
+	}
+}
@@ -1,9 +1,33 @@
 package sirdsc
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"math"
 	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrNoOutput is returned by GenerateContext if it is given a nil
+	// output image.
+	ErrNoOutput = errors.New("no output image")
+
+	// ErrNoDepthMap is returned by GenerateContext if it is given a nil
+	// depth map.
+	ErrNoDepthMap = errors.New("no depth map")
+
+	// ErrNoPattern is returned by GenerateContext if it is given a nil
+	// pattern.
+	ErrNoPattern = errors.New("no pattern")
+
+	// ErrEmptyPattern is returned by GenerateContext if the pattern
+	// that it is given has a zero width or height.
+	ErrEmptyPattern = errors.New("pattern bounds are empty")
 )
 
 // Generate generates a new SIRDS from the depth map dm and draws it
@@ -12,39 +36,370 @@ import (
 // than or equal to zero, the width of pat is used. 100 is recommended
 // as a good default, but this heavily depends on the physical size of
 // the screen that the stereogram will be displayed on.
+//
+// Generate panics if any of its arguments are invalid. To get an
+// error instead, or to be able to cancel generation, use
+// GenerateContext.
 func Generate(out draw.Image, dm DepthMap, pat image.Image, partSize int) {
-	if partSize <= 0 {
-		partSize = pat.Bounds().Dx()
+	err := GenerateContext(context.Background(), out, dm, pat, &Options{
+		PartSize: partSize,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GenerateContext is like Generate, but it is configured by opts and
+// it stops generating rows once ctx is done, in which case it returns
+// context.Cause(ctx). If opts is nil, the zero value of Options is
+// used. If the arguments are invalid, an error is returned without
+// anything being drawn to out.
+func GenerateContext(ctx context.Context, out draw.Image, dm DepthMap, pat image.Image, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	switch {
+	case out == nil:
+		return ErrNoOutput
+	case dm == nil:
+		return ErrNoDepthMap
+	case pat == nil:
+		return ErrNoPattern
+	case pat.Bounds().Empty():
+		return ErrEmptyPattern
+	}
+
+	switch opts.Mode {
+	case WallEyed, CrossEyed:
+	default:
+		return fmt.Errorf("unknown viewing mode: %v", opts.Mode)
+	}
+
+	switch opts.Propagation {
+	case PropagateRight, PropagateOut:
+	default:
+		return fmt.Errorf("unknown propagation: %v", opts.Propagation)
 	}
 
-	pat = TiledImage{
-		Image: pat,
+	switch opts.Framing {
+	case ExtraStrip, Cropped, Centered:
+	default:
+		return fmt.Errorf("unknown framing: %v", opts.Framing)
 	}
 
-	b := out.Bounds()
+	var labels func(*generator, *rowScratch)
+	switch opts.Algorithm {
+	case Copy:
+		labels = copyLabels
+	case HiddenSurface:
+		labels = hsrLabels
+	default:
+		return fmt.Errorf("unknown algorithm: %v", opts.Algorithm)
+	}
+
+	partSize := opts.partSize(pat)
+
+	var geometry *ViewingGeometry
+	if opts.Geometry != nil {
+		tmp := opts.Geometry.withDefaults()
+		geometry = &tmp
+	}
+
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxImageDepth
+	}
+
+	frame := opts.Frame
+	if frame.Empty() {
+		frame = out.Bounds()
+	}
+
+	// origin is the left edge of the unmodified strip of the pattern
+	// that each row starts from.
+	var origin int
+	if opts.Propagation == PropagateOut {
+		origin = opts.Origin
+		if origin == 0 {
+			origin = frame.Dx() / 2
+		}
+		origin = max(min(origin-partSize/2, frame.Dx()-partSize), 0)
+	}
+
+	n := max(opts.Oversample, 1)
+
+	g := generator{
+		dm:          dm,
+		frame:       frame,
+		bounds:      out.Bounds().Intersect(frame),
+		n:           n,
+		partSize:    partSize * n,
+		depthOffset: opts.depthOffset(partSize),
+		origin:      origin * n,
+		out:         opts.Propagation == PropagateOut,
+		cross:       opts.Mode == CrossEyed,
+		geometry:    geometry,
+		maxDepth:    float64(maxDepth),
+		labels:      labels,
+		painter:     newPainter(out, pat, n),
+	}
+	if g.bounds.Empty() {
+		return nil
+	}
+	if geometry != nil {
+		// Rounding the separation of the background plane after
+		// scaling it keeps the background consistent with every other
+		// depth.
+		g.partSize = g.separation(0)
+	}
+
+	pool := opts.Pool
+	if pool == nil {
+		pool = NewPool(opts.Workers)
+		defer pool.Close()
+	}
+
+	b := g.bounds
+	band := bandSize(b.Dy(), pool.Workers())
 
 	var wg sync.WaitGroup
-	wg.Add(b.Dy())
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		go func(y int) {
+	var canceled atomic.Bool
+	for y0 := b.Min.Y; y0 < b.Max.Y; y0 += band {
+		y1 := min(y0+band, b.Max.Y)
+
+		wg.Add(1)
+		task := func() {
 			defer wg.Done()
 
-			for x := b.Min.X; x < b.Max.X; x++ {
-				depth := dm.At(x-partSize, y)
+			s := scratchPool.Get().(*rowScratch)
+			defer scratchPool.Put(s)
 
-				src := pat
-				if x-partSize >= 0 {
-					src = out
+			for y := y0; y < y1; y++ {
+				if ctx.Err() != nil {
+					canceled.Store(true)
+					return
 				}
 
-				c := src.At(x-partSize, y)
-				out.Set(x, y, c)
-
-				if (depth != 0) && (x-depth >= b.Min.X) && (x-depth <= b.Max.X) {
-					out.Set(x-depth, y, c)
-				}
+				g.row(s, y)
 			}
-		}(y)
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Done()
+			canceled.Store(true)
+		case pool.tasks <- task:
+			continue
+		}
+		break
 	}
 	wg.Wait()
+
+	if canceled.Load() {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// bandsPerWorker is the number of bands that the rows of an image are
+// split into for each worker. Using more than one band per worker
+// keeps workers busy when some rows are cheaper than others.
+const bandsPerWorker = 4
+
+// bandSize returns the number of rows in each band when splitting
+// rows rows between the given number of workers.
+func bandSize(rows, workers int) int {
+	bands := workers * bandsPerWorker
+	return max((rows+bands-1)/bands, 1)
+}
+
+// A generator holds the state shared between the rows of a single
+// call to GenerateContext.
+type generator struct {
+	dm DepthMap
+
+	// frame is the bounds of the complete stereogram, and bounds is the
+	// region of it that is being drawn.
+	frame  image.Rectangle
+	bounds image.Rectangle
+
+	// n is the number of samples that are generated for each pixel.
+	// partSize, origin, and the labels and separations of each row are
+	// all in samples rather than pixels.
+	n int
+
+	// partSize is the separation of the background plane, and
+	// depthOffset is the distance in pixels from a column of the output
+	// to the column of the depth map that is shown in it.
+	partSize    int
+	depthOffset int
+
+	// origin is the offset from the left edge of the frame of the
+	// unmodified strip of the pattern that each row starts from. If
+	// out is true, constraints are propagated outwards from it instead
+	// of only to the right.
+	origin int
+	out    bool
+
+	cross    bool
+	geometry *ViewingGeometry
+	maxDepth float64
+
+	labels  func(*generator, *rowScratch)
+	painter painter
+}
+
+// row generates row y of the output. Each row is generated in two
+// steps. First, the algorithm assigns a label to every pixel in the
+// row, where a label is the x coordinate in the pattern that the
+// pixel's color comes from. Then the painter fills in the row using
+// the colors of the pattern at those coordinates.
+//
+// Labels are always assigned to the entire width of the frame, as the
+// label of a pixel can depend on pixels on either side of it, but only
+// the pixels inside of the bounds are painted.
+func (g *generator) row(s *rowScratch, y int) {
+	width := g.frame.Dx()
+	s.seps = resize(s.seps, width*g.n)
+	s.labels = resize(s.labels, width*g.n)
+
+	x := g.frame.Min.X - g.depthOffset
+	if g.n == 1 {
+		s.depth = resize(s.depth, width)
+		readDepthRow(g.dm, s.depth, x, y)
+		for i, depth := range s.depth {
+			s.seps[i] = g.separation(float64(depth))
+		}
+	} else {
+		s.depthF = resize(s.depthF, width)
+		readDepthRowF(g.dm, s.depthF, x, y)
+		g.interpolate(s.seps, s.depthF)
+	}
+	g.labels(g, s)
+
+	start := (g.bounds.Min.X - g.frame.Min.X) * g.n
+	labels := s.labels[start : start+g.bounds.Dx()*g.n]
+	g.painter.paintRow(labels, g.bounds.Min.X, y, s)
+}
+
+// interpolate fills seps with the separations of the samples of a row
+// by linearly interpolating between the depths of its pixels. Each
+// sample is treated as being at its center, so the samples in the
+// middle of a pixel have that pixel's depth.
+func (g *generator) interpolate(seps []int, depths []float64) {
+	n := float64(g.n)
+	last := len(depths) - 1
+	for i := range seps {
+		u := (float64(i)+0.5)/n - 0.5
+		x := int(math.Floor(u))
+		f := u - float64(x)
+
+		d0 := depths[max(min(x, last), 0)]
+		d1 := depths[max(min(x+1, last), 0)]
+		seps[i] = g.separation(d0 + (d1-d0)*f)
+	}
+}
+
+// separation returns the distance, in samples, between the pixels
+// that are linked by a point with the given depth.
+func (g *generator) separation(depth float64) int {
+	if g.geometry == nil {
+		d := int(math.Round(depth * float64(g.n)))
+		if g.cross {
+			return g.partSize + d
+		}
+		return g.partSize - d
+	}
+
+	z := depth / g.maxDepth
+	if g.cross {
+		z = 1 - z
+	}
+	return int(math.Round(g.geometry.Separation(z) * float64(g.n)))
+}
+
+// copyLabels labels a row using the Copy algorithm. s.seps must hold
+// the separations for the row.
+//
+// Each pixel to the right of the origin is copied from partSize
+// pixels to its left, and that color is then also given to the pixel
+// that is depth pixels to its left, linking that pixel to the one that
+// the color came from. If the depth is negative, the linked pixel is
+// to the right instead. That link is deferred until the linked pixel
+// is reached so that it takes precedence over that pixel's own copy
+// from the left, which would otherwise overwrite it. Pixels to the
+// left of the origin are labeled in the same way, but mirrored.
+func copyLabels(g *generator, s *rowScratch) {
+	s.pending = resize(s.pending, len(s.seps))
+	for i := range s.pending {
+		s.pending[i] = noLabel
+	}
+
+	x0 := g.frame.Min.X * g.n
+	for i := g.origin; i < len(s.seps); i++ {
+		depth := g.partSize - s.seps[i]
+
+		c := x0 + i - g.partSize
+		if i-g.partSize >= g.origin {
+			c = s.labels[i-g.partSize]
+		}
+		copyLink(s, i, i-depth, depth, c, g.origin, len(s.labels))
+	}
+
+	for i := g.origin - 1; i >= 0; i-- {
+		depth := g.partSize - s.seps[i]
+		copyLink(s, i, i+depth, depth, s.labels[i+g.partSize], 0, g.origin)
+	}
+}
+
+// copyLink gives pixel i the label c, or its pending label if it has
+// one, and links pixel j to it. j is ignored if it is outside of
+// [start, end).
+func copyLink(s *rowScratch, i, j, depth, c, start, end int) {
+	s.labels[i] = c
+	if s.pending[i] != noLabel {
+		s.labels[i] = s.pending[i]
+	}
+
+	if (depth == 0) || (j < start) || (j >= end) {
+		return
+	}
+	if depth > 0 {
+		s.labels[j] = c
+		return
+	}
+	s.pending[j] = c
+}
+
+// noLabel marks a pixel in rowScratch.pending that has no pending
+// label.
+const noLabel = math.MinInt
+
+// rowScratch holds the buffers used while generating a single row.
+// They are pooled and reused so that generating rows doesn't allocate.
+type rowScratch struct {
+	depth   []int
+	depthF  []float64
+	labels  []int
+	seps    []int
+	pending []int
+
+	cache   []uint8
+	filled  []bool
+	colors  []color.Color
+	samples []sample
+}
+
+var scratchPool = sync.Pool{
+	New: func() any { return new(rowScratch) },
+}
+
+// resize returns a slice of length n, reusing the memory of s if it
+// is large enough. The contents of the returned slice are undefined.
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
 }
@@ -0,0 +1,252 @@
+package sirdsc
+
+import (
+	"image"
+	"math"
+
+	"github.com/DeedleFake/sirdsc/spcg"
+)
+
+const (
+	// DefaultTerrainOctaves is the number of octaves used by
+	// NewTerrainDepthMap if none is specified.
+	DefaultTerrainOctaves = 8
+
+	// DefaultTerrainRoughness is the roughness used by
+	// NewTerrainDepthMap if none is specified.
+	DefaultTerrainRoughness = 0.5
+)
+
+// TerrainOptions are the options for generating a TerrainDepthMap.
+type TerrainOptions struct {
+	// Seed is the seed that the terrain is generated from. Terrain
+	// generated with the same seed and options at the same size is
+	// always the same.
+	Seed uint64
+
+	// Octaves is the number of levels of detail in the terrain. Each
+	// level has features half the size of the previous one. If it is
+	// zero, DefaultTerrainOctaves is used.
+	Octaves int
+
+	// Roughness is how much the height of the features of each level
+	// of detail is multiplied by relative to the previous one. Values
+	// close to 1 make jagged terrain, and values close to 0 make smooth,
+	// rolling terrain. If it is zero, DefaultTerrainRoughness is used.
+	Roughness float64
+
+	// SeaLevel is the fraction of the range of heights of the terrain
+	// that is under water. The water is flat and has a depth of zero.
+	SeaLevel float64
+
+	// Island is the strength, from 0 to 1, of a falloff that lowers the
+	// terrain towards the edges, turning it into an island. At 1, the
+	// edges are always at a depth of zero.
+	Island float64
+
+	// Depth is the depth of the highest point of the terrain. If it is
+	// zero, DefaultMaxImageDepth is used.
+	Depth float64
+}
+
+// TerrainDepthMap is a depth map of procedurally generated terrain.
+// Its bounds start at (0, 0).
+type TerrainDepthMap struct {
+	*Depth
+}
+
+// NewTerrainDepthMap generates a TerrainDepthMap with the given size.
+// If opts is nil, the defaults for all of the options are used.
+//
+// The terrain is the sum of a height map generated with the
+// diamond-square algorithm, which gives it its large-scale structure,
+// and fractal Brownian motion built from value noise, which breaks up
+// the straight creases that diamond-square leaves behind. Both have
+// opts.Octaves levels of detail.
+func NewTerrainDepthMap(size image.Point, opts *TerrainOptions) *TerrainDepthMap {
+	if opts == nil {
+		opts = &TerrainOptions{}
+	}
+	octaves := opts.Octaves
+	if octaves <= 0 {
+		octaves = DefaultTerrainOctaves
+	}
+	roughness := opts.Roughness
+	if roughness == 0 {
+		roughness = DefaultTerrainRoughness
+	}
+	depth := opts.Depth
+	if depth == 0 {
+		depth = DefaultMaxImageDepth
+	}
+
+	d := NewDepth(image.Rectangle{Max: size})
+	w, h := d.Rect.Dx(), d.Rect.Dy()
+	if (w == 0) || (h == 0) {
+		return &TerrainDepthMap{Depth: d}
+	}
+
+	ds, stride, n := diamondSquare(opts.Seed, w, h, octaves, roughness)
+	heights := make([]float64, w*h)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for y := range h {
+		for x := range w {
+			v := ds[y*stride+x] + fbm(opts.Seed, float64(x), float64(y), float64(n-1)/2, octaves, roughness)
+			heights[y*w+x] = v
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+
+	// The heights are normalized to [0, 1] before the falloff is
+	// applied so that its strength doesn't depend on the roughness.
+	top := math.Inf(-1)
+	for y := range h {
+		for x := range w {
+			v := (heights[y*w+x] - lo) / max(hi-lo, 1e-12)
+			if opts.Island > 0 {
+				// The distances are scaled so that the outermost pixels are
+				// at a distance of 1.
+				dx := (float64(x) + 0.5 - float64(w)/2) / max(float64(w-1)/2, 0.5)
+				dy := (float64(y) + 0.5 - float64(h)/2) / max(float64(h-1)/2, 0.5)
+				v -= opts.Island * (dx*dx + dy*dy)
+			}
+			heights[y*w+x] = v
+			top = max(top, v)
+		}
+	}
+
+	sea := min(max(opts.SeaLevel, 0), 1)
+	if top <= sea {
+		return &TerrainDepthMap{Depth: d}
+	}
+	for y := range h {
+		row := d.Pix[d.PixOffset(0, y):]
+		for x := range w {
+			v := max(heights[y*w+x]-sea, 0) / (top - sea)
+			row[x] = float32(v * depth)
+		}
+	}
+
+	return &TerrainDepthMap{Depth: d}
+}
+
+// terrainRand returns a random number in the range [-1, 1) for the
+// point (x, y) of the layer of terrain with the given seed. The same
+// arguments always return the same number.
+func terrainRand(seed uint64, layer, x, y int) float64 {
+	// Multiplying the layer by the golden ratio keeps the layers of
+	// nearby seeds from overlapping.
+	n, _, _ := spcg.Next(seed+uint64(layer)*0x9e3779b97f4a7c15, uint64(uint32(x))<<32|uint64(uint32(y)))
+	return float64(n>>11)/(1<<52) - 1
+}
+
+// diamondSquare generates a height map of at least w by h with the
+// diamond-square algorithm. The height map is a row or column of n by n
+// squares that share their edges, where n is the smallest number one
+// more than a power of two that is at least the smaller of w and h, so
+// that the size of the height map grows with w and h instead of with
+// the square of the larger of them. Its rows are stride apart. Only the
+// first octaves levels of detail are displaced randomly, each by
+// roughness times as much as the previous one, and the rest are
+// interpolated.
+func diamondSquare(seed uint64, w, h, octaves int, roughness float64) (heights []float64, stride, n int) {
+	n = 2
+	for n < min(w, h) {
+		n = 2*(n-1) + 1
+	}
+	cols := max((w-1+n-2)/(n-1), 1)
+	rows := max((h-1+n-2)/(n-1), 1)
+	stride, height := cols*(n-1)+1, rows*(n-1)+1
+	heights = make([]float64, stride*height)
+	at := func(x, y int) *float64 { return &heights[y*stride+x] }
+
+	// Each point is only displaced once, so they can all share the
+	// same layer of random numbers.
+	for y := 0; y < height; y += n - 1 {
+		for x := 0; x < stride; x += n - 1 {
+			*at(x, y) = terrainRand(seed, 0, x, y)
+		}
+	}
+
+	amp := 1.0
+	for level, step := 0, n-1; step > 1; level, step = level+1, step/2 {
+		half := step / 2
+		displace := func(x, y int) float64 {
+			if level >= octaves {
+				return 0
+			}
+			return amp * terrainRand(seed, 0, x, y)
+		}
+
+		// The diamond step sets the center of each square to the average
+		// of its corners.
+		for y := half; y < height; y += step {
+			for x := half; x < stride; x += step {
+				avg := (*at(x-half, y-half) + *at(x+half, y-half) + *at(x-half, y+half) + *at(x+half, y+half)) / 4
+				*at(x, y) = avg + displace(x, y)
+			}
+		}
+
+		// The square step sets the middle of each edge to the average of
+		// the points around it, of which there are only three along the
+		// edges of the height map.
+		for y := 0; y < height; y += half {
+			for x := (y/half + 1) % 2 * half; x < stride; x += step {
+				var sum float64
+				var count int
+				for _, p := range [...]image.Point{{x - half, y}, {x + half, y}, {x, y - half}, {x, y + half}} {
+					if (p.X >= 0) && (p.X < stride) && (p.Y >= 0) && (p.Y < height) {
+						sum += *at(p.X, p.Y)
+						count++
+					}
+				}
+				*at(x, y) = sum/float64(count) + displace(x, y)
+			}
+		}
+
+		amp *= roughness
+	}
+
+	return heights, stride, n
+}
+
+// fbm returns the value of fractal Brownian motion at (x, y). The
+// first of its octaves has features that are about scale wide, and
+// each of the rest has features half the size and roughness times the
+// height of the previous one. Each octave is a separate layer of value
+// noise, starting after the layer used by diamondSquare.
+func fbm(seed uint64, x, y, scale float64, octaves int, roughness float64) float64 {
+	var v float64
+	amp := 1.0
+	for i := range octaves {
+		if scale < 1 {
+			break
+		}
+		v += amp * valueNoise(seed, i+1, x/scale, y/scale)
+		scale /= 2
+		amp *= roughness
+	}
+	return v
+}
+
+// valueNoise returns smoothly interpolated noise at (x, y) from the
+// random numbers of the given layer at the surrounding integer points.
+func valueNoise(seed uint64, layer int, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	ix, iy := int(x0), int(y0)
+	tx, ty := smootherstep(x-x0), smootherstep(y-y0)
+
+	v00 := terrainRand(seed, layer, ix, iy)
+	v10 := terrainRand(seed, layer, ix+1, iy)
+	v01 := terrainRand(seed, layer, ix, iy+1)
+	v11 := terrainRand(seed, layer, ix+1, iy+1)
+	top := v00 + (v10-v00)*tx
+	bottom := v01 + (v11-v01)*tx
+	return top + (bottom-top)*ty
+}
+
+// smootherstep eases t, which is in the range [0, 1], in and out so
+// that interpolating with it doesn't leave creases.
+func smootherstep(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
@@ -0,0 +1,90 @@
+package sirdsc_test
+
+import (
+	"image"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestTerrainDepthMap(t *testing.T) {
+	size := image.Pt(120, 80)
+	gen := func(opts sirdsc.TerrainOptions) *sirdsc.TerrainDepthMap {
+		tm := sirdsc.NewTerrainDepthMap(size, &opts)
+		if b := tm.Bounds(); b != (image.Rectangle{Max: size}) {
+			t.Fatalf("bounds %v", b)
+		}
+		return tm
+	}
+	zeros := func(tm *sirdsc.TerrainDepthMap) (n int) {
+		for _, v := range tm.Pix {
+			if v == 0 {
+				n++
+			}
+		}
+		return n
+	}
+
+	tm := gen(sirdsc.TerrainOptions{Seed: 1, Depth: 30})
+	var hi float32
+	for _, v := range tm.Pix {
+		if (v < 0) || (v > 30) {
+			t.Fatalf("depth %v out of range", v)
+		}
+		hi = max(hi, v)
+	}
+	if hi != 30 {
+		t.Errorf("highest depth %v, want 30", hi)
+	}
+
+	if !reflect.DeepEqual(gen(sirdsc.TerrainOptions{Seed: 1, Depth: 30}), tm) {
+		t.Error("same seed generated different terrain")
+	}
+	if reflect.DeepEqual(gen(sirdsc.TerrainOptions{Seed: 2, Depth: 30}), tm) {
+		t.Error("different seeds generated the same terrain")
+	}
+
+	// Raising the sea level floods more of the terrain.
+	if dry, wet := zeros(tm), zeros(gen(sirdsc.TerrainOptions{Seed: 1, SeaLevel: 0.5})); wet <= dry+len(tm.Pix)/10 {
+		t.Errorf("%v points under water at sea level 0.5, %v at 0", wet, dry)
+	}
+
+	// A full-strength island falloff leaves the edges under water.
+	island := gen(sirdsc.TerrainOptions{Seed: 1, Island: 1})
+	for x := range size.X {
+		if (island.AtF(x, 0) != 0) || (island.AtF(x, size.Y-1) != 0) {
+			t.Fatalf("island's edge isn't under water at x = %v", x)
+		}
+	}
+	for y := range size.Y {
+		if (island.AtF(0, y) != 0) || (island.AtF(size.X-1, y) != 0) {
+			t.Fatalf("island's edge isn't under water at y = %v", y)
+		}
+	}
+}
+
+func TestTerrainDepthMapWide(t *testing.T) {
+	// Long, thin terrain is generated from a row of small squares, so
+	// every part of it has its own features.
+	for _, size := range []image.Point{{4000, 10}, {10, 4000}, {1, 1}, {3, 2}} {
+		tm := sirdsc.NewTerrainDepthMap(size, nil)
+		if b := tm.Bounds(); b != (image.Rectangle{Max: size}) {
+			t.Fatalf("%v: bounds %v", size, b)
+		}
+
+		top := slices.Max(tm.Pix)
+		if (size.X*size.Y > 1) && (top != sirdsc.DefaultMaxImageDepth) {
+			t.Errorf("%v: highest depth %v, want %v", size, top, sirdsc.DefaultMaxImageDepth)
+		}
+
+		distinct := make(map[float32]struct{})
+		for _, v := range tm.Pix {
+			distinct[v] = struct{}{}
+		}
+		if len(distinct) < len(tm.Pix)*9/10 {
+			t.Errorf("%v: only %v distinct depths in %v pixels", size, len(distinct), len(tm.Pix))
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package sirdsc
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultTextSize is the size of text used by NewTextDepthMap if none
+// is specified.
+const DefaultTextSize = 64
+
+// TextAlign is the horizontal alignment of the lines of a multi-line
+// TextDepthMap.
+type TextAlign int
+
+const (
+	// AlignLeft lines the left edges of the lines up.
+	AlignLeft TextAlign = iota
+
+	// AlignCenter centers each line on the widest one.
+	AlignCenter
+
+	// AlignRight lines the right edges of the lines up.
+	AlignRight
+)
+
+func (a TextAlign) String() string {
+	switch a {
+	case AlignLeft:
+		return "left"
+	case AlignCenter:
+		return "center"
+	case AlignRight:
+		return "right"
+	default:
+		return fmt.Sprintf("TextAlign(%d)", int(a))
+	}
+}
+
+// TextOptions are the options for rendering a TextDepthMap.
+type TextOptions struct {
+	// Font is the font to render the text with. If it is nil,
+	// basicfont.Face7x13 is used, scaled up to Size without any
+	// smoothing.
+	Font *opentype.Font
+
+	// Size is the size of the text in pixels. If it is zero,
+	// DefaultTextSize is used.
+	Size float64
+
+	// Align is the alignment of the lines of the text relative to each
+	// other.
+	Align TextAlign
+
+	// LineSpacing is the distance between the baselines of lines, as a
+	// multiple of the font's line height. If it is zero, it is 1.
+	LineSpacing float64
+
+	// Depth is the depth of the text. If it is zero,
+	// DefaultMaxImageDepth is used.
+	Depth float64
+
+	// Bevel is the width, in pixels, of the slopes that the edges of
+	// the letters rise from the background along. If it is zero, the
+	// letters are flat.
+	Bevel float64
+
+	// Round rounds the slopes off instead of making them straight. If
+	// Bevel is zero, the letters are rounded all of the way from their
+	// edges to their centers.
+	Round bool
+
+	// Padding is the width of the margin around the text. The margin
+	// has a depth of zero.
+	Padding int
+}
+
+// TextDepthMap is a depth map of rendered text. Its bounds start at
+// (0, 0) and fit around the text and its padding.
+type TextDepthMap struct {
+	*Depth
+}
+
+// NewTextDepthMap renders text, which may contain multiple lines, into
+// a TextDepthMap. If opts is nil, the defaults for all of the options
+// are used.
+func NewTextDepthMap(text string, opts *TextOptions) (*TextDepthMap, error) {
+	if opts == nil {
+		opts = &TextOptions{}
+	}
+	face, scale, err := textFace(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	mask := renderText(face, text, opts.Align, opts.LineSpacing)
+	if scale != 1 {
+		scaled := image.NewAlpha(scaleRect(mask.Bounds(), scale))
+		draw.NearestNeighbor.Scale(scaled, scaled.Rect, mask, mask.Bounds(), draw.Src, nil)
+		mask = scaled
+	}
+
+	depth := opts.Depth
+	if depth == 0 {
+		depth = DefaultMaxImageDepth
+	}
+
+	pad := max(opts.Padding, 0)
+	r := mask.Bounds()
+	d := NewDepth(image.Rect(0, 0, r.Dx()+2*pad, r.Dy()+2*pad))
+	if (opts.Bevel <= 0) && !opts.Round {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				a := float64(mask.AlphaAt(x, y).A) / math.MaxUint8
+				d.Pix[d.PixOffset(x-r.Min.X+pad, y-r.Min.Y+pad)] = float32(depth * a)
+			}
+		}
+		return &TextDepthMap{Depth: d}, nil
+	}
+
+	dist := distanceTransform(mask)
+	bevel := opts.Bevel
+	if bevel <= 0 {
+		bevel = 1
+		for _, v := range dist {
+			bevel = max(bevel, v)
+		}
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			t := min(dist[(y-r.Min.Y)*r.Dx()+(x-r.Min.X)]/bevel, 1)
+			if opts.Round {
+				t = math.Sqrt(1 - (1-t)*(1-t))
+			}
+			d.Pix[d.PixOffset(x-r.Min.X+pad, y-r.Min.Y+pad)] = float32(depth * t)
+		}
+	}
+	return &TextDepthMap{Depth: d}, nil
+}
+
+// TextBounds returns the bounds of the TextDepthMap that
+// NewTextDepthMap would render text into with opts without rendering
+// it, so that text that is too large can be rejected cheaply.
+func TextBounds(text string, opts *TextOptions) (image.Rectangle, error) {
+	if opts == nil {
+		opts = &TextOptions{}
+	}
+	face, scale, err := textFace(opts)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer face.Close()
+
+	_, r := layoutText(face, text, opts.Align, opts.LineSpacing)
+	if scale != 1 {
+		r = scaleRect(r, scale)
+	}
+	pad := max(opts.Padding, 0)
+	return image.Rect(0, 0, r.Dx()+2*pad, r.Dy()+2*pad), nil
+}
+
+// textFace returns the face to render text with for opts and the
+// scale that the rendered text needs to be resized by to be the right
+// size.
+func textFace(opts *TextOptions) (face font.Face, scale float64, err error) {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultTextSize
+	}
+
+	if opts.Font == nil {
+		return basicfont.Face7x13, size / float64(basicfont.Face7x13.Height), nil
+	}
+
+	f, err := opentype.NewFace(opts.Font, &opentype.FaceOptions{
+		Size: size,
+		DPI:  72,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("create face: %w", err)
+	}
+	return f, 1, nil
+}
+
+// scaleRect returns a rectangle at the origin with the size of r
+// multiplied by scale.
+func scaleRect(r image.Rectangle, scale float64) image.Rectangle {
+	return image.Rect(
+		0,
+		0,
+		int(math.Round(float64(r.Dx())*scale)),
+		int(math.Round(float64(r.Dy())*scale)),
+	)
+}
+
+// renderText renders the lines of text with face into an alpha mask
+// that fits around them with a transparent border of one pixel.
+func renderText(face font.Face, text string, align TextAlign, spacing float64) *image.Alpha {
+	dots, r := layoutText(face, text, align, spacing)
+	mask := image.NewAlpha(r)
+	drawer := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+	}
+	for i, line := range strings.Split(text, "\n") {
+		drawer.Dot = dots[i]
+		drawer.DrawString(line)
+	}
+
+	// Move the mask to the origin so that scaling it is simpler.
+	mask.Rect = mask.Rect.Sub(r.Min)
+	return mask
+}
+
+// layoutText returns the starting points of the baselines of the lines
+// of text when they are rendered with face and the bounds of the mask
+// that renderText renders them into.
+func layoutText(face font.Face, text string, align TextAlign, spacing float64) ([]fixed.Point26_6, image.Rectangle) {
+	if spacing == 0 {
+		spacing = 1
+	}
+	height := fixed.Int26_6(math.Round(float64(face.Metrics().Height) * spacing))
+
+	lines := strings.Split(text, "\n")
+	widths := make([]fixed.Int26_6, len(lines))
+	var width fixed.Int26_6
+	for i, line := range lines {
+		widths[i] = font.MeasureString(face, line)
+		width = max(width, widths[i])
+	}
+
+	// The bounds of the glyphs can extend past their advances, so the
+	// mask is made to fit around the bounds of all of the lines rather
+	// than their widths.
+	dots := make([]fixed.Point26_6, len(lines))
+	var bounds fixed.Rectangle26_6
+	for i, line := range lines {
+		dot := fixed.Point26_6{Y: height * fixed.Int26_6(i)}
+		switch align {
+		case AlignCenter:
+			dot.X = (width - widths[i]) / 2
+		case AlignRight:
+			dot.X = width - widths[i]
+		}
+		dots[i] = dot
+
+		b, _ := font.BoundString(face, line)
+		b.Min, b.Max = b.Min.Add(dot), b.Max.Add(dot)
+		bounds = bounds.Union(b)
+	}
+
+	r := image.Rect(
+		bounds.Min.X.Floor(),
+		bounds.Min.Y.Floor(),
+		bounds.Max.X.Ceil(),
+		bounds.Max.Y.Ceil(),
+	).Inset(-1)
+	return dots, r
+}
+
+// distanceTransform returns the distance from every pixel of mask that
+// is at least half opaque to the nearest pixel that isn't, indexed in
+// the same way as a Depth with the same bounds. The distances of the
+// other pixels are zero. Pixels outside of mask are treated as
+// transparent.
+//
+// It uses the algorithm from "Distance Transforms of Sampled
+// Functions" by Felzenszwalb and Huttenlocher, which calculates exact
+// Euclidean distances in linear time.
+func distanceTransform(mask *image.Alpha) []float64 {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+
+	// Pixels just outside of the mask are transparent, so no distance
+	// can be larger than this.
+	inf := float64(w*w + h*h)
+
+	dist := make([]float64, w*h)
+	for y := range h {
+		for x := range w {
+			if mask.AlphaAt(r.Min.X+x, r.Min.Y+y).A >= 0x80 {
+				dist[y*w+x] = inf
+			}
+		}
+	}
+
+	n := max(w, h) + 2
+	f := make([]float64, n)
+	out := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+
+	// The transform is separable, so it's done on every column, and
+	// then on every row of the result. Each line is padded with a
+	// transparent pixel on both ends.
+	for x := range w {
+		f[0], f[h+1] = 0, 0
+		for y := range h {
+			f[y+1] = dist[y*w+x]
+		}
+		distanceTransform1D(f[:h+2], out, v, z)
+		for y := range h {
+			dist[y*w+x] = out[y+1]
+		}
+	}
+	for y := range h {
+		f[0], f[w+1] = 0, 0
+		copy(f[1:], dist[y*w:(y+1)*w])
+		distanceTransform1D(f[:w+2], out, v, z)
+		for x := range w {
+			dist[y*w+x] = math.Sqrt(out[x+1])
+		}
+	}
+
+	return dist
+}
+
+// distanceTransform1D calculates the squared distance transform of the
+// sampled function f into out. v and z are scratch space, and must be
+// at least as long as f and one longer, respectively.
+func distanceTransform1D(f, out []float64, v []int, z []float64) {
+	intersect := func(q, p int) float64 {
+		return ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*(q-p))
+	}
+
+	k := 0
+	v[0] = 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < len(f); q++ {
+		s := intersect(q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersect(q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+
+	k = 0
+	for q := range f {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d := q - v[k]
+		out[q] = float64(d*d) + f[v[k]]
+	}
+}
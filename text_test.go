@@ -0,0 +1,145 @@
+package sirdsc_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+)
+
+// inkColumns returns the range of columns of rows [y0, y1) of dm that
+// have a depth greater than zero.
+func inkColumns(dm sirdsc.DepthMapF, y0, y1 int) (x0, x1 int) {
+	r := dm.Bounds()
+	x0, x1 = r.Max.X, r.Min.X
+	for y := y0; y < y1; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if dm.AtF(x, y) > 0 {
+				x0, x1 = min(x0, x), max(x1, x+1)
+			}
+		}
+	}
+	return x0, x1
+}
+
+func TestTextDepthMap(t *testing.T) {
+	dm, err := sirdsc.NewTextDepthMap("I\nIII", &sirdsc.TextOptions{
+		Size:    26,
+		Align:   sirdsc.AlignRight,
+		Depth:   10,
+		Padding: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := dm.Bounds()
+	if r.Min != (image.Point{}) {
+		t.Fatalf("bounds: %v", r)
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Min.Y+3; y++ {
+			if d := dm.AtF(x, y); d != 0 {
+				t.Fatalf("padding at (%v, %v) has depth %v", x, y, d)
+			}
+		}
+	}
+
+	var found bool
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			switch d := dm.AtF(x, y); d {
+			case 0:
+			case 10:
+				found = true
+			default:
+				t.Fatalf("basicfont at (%v, %v) has depth %v", x, y, d)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no text was rendered")
+	}
+
+	// The first line is right-aligned, so it should end where the
+	// second one does, and start much later.
+	mid := r.Dy() / 2
+	top0, top1 := inkColumns(dm, 0, mid)
+	bottom0, bottom1 := inkColumns(dm, mid, r.Max.Y)
+	if (top1 != bottom1) || (top0-bottom0 < 20) {
+		t.Fatalf("top line %v-%v, bottom line %v-%v", top0, top1, bottom0, bottom1)
+	}
+}
+
+func TestTextDepthMapBevel(t *testing.T) {
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, round := range []bool{false, true} {
+		dm, err := sirdsc.NewTextDepthMap("H", &sirdsc.TextOptions{
+			Font:  f,
+			Size:  64,
+			Depth: 20,
+			Bevel: 3,
+			Round: round,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var top, slope int
+		r := dm.Bounds()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				switch d := dm.AtF(x, y); {
+				case d == 20:
+					top++
+				case (d > 0) && (d < 20):
+					slope++
+				case (d < 0) || (d > 20) || math.IsNaN(d):
+					t.Fatalf("round: %v: (%v, %v) has depth %v", round, x, y, d)
+				}
+			}
+		}
+		if (top == 0) || (slope == 0) {
+			t.Fatalf("round: %v: %v points on top, %v on the slopes", round, top, slope)
+		}
+	}
+}
+
+func TestTextBounds(t *testing.T) {
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts *sirdsc.TextOptions
+	}{
+		{"Default", nil},
+		{"Basic", &sirdsc.TextOptions{Size: 40, LineSpacing: 1.5, Padding: 7}},
+		{"Font", &sirdsc.TextOptions{Font: f, Size: 33, Align: sirdsc.AlignCenter, Padding: 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := "Wide\nlines"
+			r, err := sirdsc.TextBounds(text, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dm, err := sirdsc.NewTextDepthMap(text, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r != dm.Bounds() {
+				t.Fatalf("TextBounds returned %v, but the text was rendered into %v", r, dm.Bounds())
+			}
+		})
+	}
+}
@@ -45,3 +45,56 @@ func TestTiledImage(t *testing.T) {
 		t.Fatalf("c1 == %#v\nc2 == %#v", c1, c2)
 	}
 }
+
+func TestTiledImageOffset(t *testing.T) {
+	rect := image.Rect(-3, 4, 5, 9)
+	img := sirdsc.TiledImage{
+		Image: subImage{
+			img:  &sirdsc.RandImage{Seed: 1},
+			rect: rect,
+		},
+	}
+
+	for y := -20; y < 20; y++ {
+		for x := -20; x < 20; x++ {
+			c := img.At(x, y)
+			for _, p := range []image.Point{{x + rect.Dx(), y}, {x, y - rect.Dy()}, {x - 3*rect.Dx(), y + 2*rect.Dy()}} {
+				if c2 := img.At(p.X, p.Y); c != c2 {
+					t.Fatalf("(%v, %v) == %#v\n%v == %#v", x, y, c, p, c2)
+				}
+			}
+
+			tx, ty := x, y
+			for tx < rect.Min.X {
+				tx += rect.Dx()
+			}
+			for tx >= rect.Max.X {
+				tx -= rect.Dx()
+			}
+			for ty < rect.Min.Y {
+				ty += rect.Dy()
+			}
+			for ty >= rect.Max.Y {
+				ty -= rect.Dy()
+			}
+			if c2 := img.Image.At(tx, ty); c != c2 {
+				t.Fatalf("(%v, %v) == %#v\n(%v, %v) == %#v", x, y, c, tx, ty, c2)
+			}
+		}
+	}
+}
+
+func TestTiledImageUnbounded(t *testing.T) {
+	// Points inside of the bounds of an image are never moved, even
+	// when the bounds extend below zero, as RandImage's do. Before this
+	// was fixed, negative coordinates were moved outside of the bounds,
+	// so stereograms of random patterns were drawn from different parts
+	// of the pattern.
+	pat := sirdsc.RandImage{Seed: 5}
+	img := sirdsc.TiledImage{Image: pat}
+	for _, p := range []image.Point{{0, 0}, {-5, 3}, {7, -2}, {-100, -100}} {
+		if c, want := img.At(p.X, p.Y), pat.At(p.X, p.Y); c != want {
+			t.Errorf("%v == %#v, want %#v", p, c, want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package sirdsc
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Transfer is a curve that adjusts the values of the pixels of an
+// ImageDepthMap before they are converted to depths. Values are
+// normalized so that 0 is black and 1 is the maximum value of a pixel,
+// and a Transfer should return a value in the same range.
+type Transfer func(v float64) float64
+
+// Compose returns a Transfer that applies each of transfers in order.
+// Nil transfers are skipped.
+func Compose(transfers ...Transfer) Transfer {
+	transfers = slices.DeleteFunc(slices.Clone(transfers), func(t Transfer) bool { return t == nil })
+	return func(v float64) float64 {
+		for _, t := range transfers {
+			v = t(v)
+		}
+		return v
+	}
+}
+
+// Gamma returns a Transfer that applies a gamma correction of g. A g
+// greater than 1 brightens mid-tones, pulling them closer to the
+// viewer, while a g less than 1 darkens them. A g less than or equal
+// to zero is treated as 1.
+func Gamma(g float64) Transfer {
+	if g <= 0 {
+		g = 1
+	}
+	return func(v float64) float64 {
+		return math.Pow(max(v, 0), 1/g)
+	}
+}
+
+// Posterize returns a Transfer that reduces values to n evenly spaced
+// levels, from 0 to 1 inclusive. This turns smooth gradients into a
+// series of flat layers. If n is less than 2, values are not changed.
+func Posterize(n int) Transfer {
+	if n < 2 {
+		return func(v float64) float64 { return v }
+	}
+	return func(v float64) float64 {
+		level := min(math.Floor(v*float64(n)), float64(n-1))
+		return max(level, 0) / float64(n-1)
+	}
+}
+
+// A CurvePoint is a point on a curve created by Curve. In is a value
+// of a pixel and Out is the value that it is mapped to.
+type CurvePoint struct {
+	In, Out float64
+}
+
+// Curve returns a piecewise-linear Transfer that passes through the
+// given points, which do not need to be sorted. Values before the
+// first point or after the last one are mapped to the Out of that
+// point. If no points are given, values are not changed.
+func Curve(points ...CurvePoint) Transfer {
+	if len(points) == 0 {
+		return func(v float64) float64 { return v }
+	}
+
+	points = slices.Clone(points)
+	slices.SortStableFunc(points, func(p1, p2 CurvePoint) int {
+		switch {
+		case p1.In < p2.In:
+			return -1
+		case p1.In > p2.In:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return func(v float64) float64 {
+		i, _ := slices.BinarySearchFunc(points, v, func(p CurvePoint, v float64) int {
+			switch {
+			case p.In < v:
+				return -1
+			case p.In > v:
+				return 1
+			default:
+				return 0
+			}
+		})
+		switch {
+		case i == 0:
+			return points[0].Out
+		case i == len(points):
+			return points[len(points)-1].Out
+		}
+
+		p1, p2 := points[i-1], points[i]
+		if p2.In == v {
+			return p2.Out
+		}
+		f := (v - p1.In) / (p2.In - p1.In)
+		return p1.Out + (p2.Out-p1.Out)*f
+	}
+}
+
+// ParseCurve parses a list of curve points for Curve in the form
+// "in:out,in:out,...". Spaces around the numbers are ignored. An empty
+// string is a curve with no points.
+func ParseCurve(str string) ([]CurvePoint, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var points []CurvePoint
+	for _, p := range strings.Split(str, ",") {
+		in, out, ok := strings.Cut(p, ":")
+		if !ok {
+			return nil, fmt.Errorf("point %q is not of the form in:out", p)
+		}
+		x, err := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, CurvePoint{In: x, Out: y})
+	}
+	return points, nil
+}
+
+// AutoLevels returns a Transfer that linearly stretches the range of
+// values that are actually present in dm's image to the full range
+// from 0 to 1. dm's Channel and AlphaMask are used when reading the
+// values, and, if AlphaMask is true, transparent pixels are ignored.
+// dm's own Transfer is not applied.
+func (dm ImageDepthMap) AutoLevels() Transfer {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	dm.eachValue(func(v float64) {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	})
+	if !(hi > lo) {
+		return func(v float64) float64 { return v }
+	}
+
+	return func(v float64) float64 {
+		return min(max((v-lo)/(hi-lo), 0), 1)
+	}
+}
+
+// Equalize returns a Transfer that performs histogram equalization of
+// the values in dm's image, spreading them out so that each depth is
+// used by roughly the same number of pixels. The values are read in
+// the same way as they are by AutoLevels.
+func (dm ImageDepthMap) Equalize() Transfer {
+	const bins = math.MaxUint16 + 1
+
+	cdf := make([]float64, bins)
+	var total float64
+	dm.eachValue(func(v float64) {
+		cdf[int(math.Round(v*math.MaxUint16))]++
+		total++
+	})
+
+	var sum, first float64
+	for i, n := range cdf {
+		if (sum == 0) && (n != 0) {
+			first = n
+		}
+		sum += n
+		cdf[i] = sum
+	}
+	if total <= first {
+		return func(v float64) float64 { return v }
+	}
+
+	return func(v float64) float64 {
+		i := int(math.Round(min(max(v, 0), 1) * math.MaxUint16))
+		return max(cdf[i]-first, 0) / (total - first)
+	}
+}
+
+// eachValue calls yield with the normalized value of every pixel of
+// dm's image. If dm.AlphaMask is true, transparent pixels are skipped.
+func (dm ImageDepthMap) eachValue(yield func(v float64)) {
+	b := dm.Image.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := dm.Image.At(x, y).RGBA()
+			if dm.AlphaMask && (a == 0) {
+				continue
+			}
+			yield(min(dm.value(r, g, b, a)/math.MaxUint16, 1))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sirdsc_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/DeedleFake/sirdsc"
+)
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name string
+		t    sirdsc.Transfer
+		in   []float64
+		want []float64
+	}{
+		{
+			name: "Gamma",
+			t:    sirdsc.Gamma(2),
+			in:   []float64{0, 0.25, 1},
+			want: []float64{0, 0.5, 1},
+		},
+		{
+			name: "Posterize",
+			t:    sirdsc.Posterize(3),
+			in:   []float64{0, 0.3, 0.4, 0.7, 1},
+			want: []float64{0, 0, 0.5, 1, 1},
+		},
+		{
+			name: "Curve",
+			t:    sirdsc.Curve(sirdsc.CurvePoint{1, 0.5}, sirdsc.CurvePoint{0.2, 0.2}, sirdsc.CurvePoint{0.6, 1}),
+			in:   []float64{0, 0.2, 0.4, 0.6, 0.8, 1},
+			want: []float64{0.2, 0.2, 0.6, 1, 0.75, 0.5},
+		},
+		{
+			name: "Compose",
+			t:    sirdsc.Compose(sirdsc.Gamma(0.5), nil, sirdsc.Posterize(2)),
+			in:   []float64{0.6, 0.8},
+			want: []float64{0, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, in := range test.in {
+				if got := test.t(in); math.Abs(got-test.want[i]) > 1e-9 {
+					t.Errorf("%v: got %v, want %v", in, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCurve(t *testing.T) {
+	points, err := sirdsc.ParseCurve("0:0.2, 0.5 : 1,1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sirdsc.CurvePoint{{In: 0, Out: 0.2}, {In: 0.5, Out: 1}, {In: 1, Out: 0}}
+	if !slices.Equal(points, want) {
+		t.Errorf("got %v, want %v", points, want)
+	}
+
+	if points, err := sirdsc.ParseCurve(""); (points != nil) || (err != nil) {
+		t.Errorf("empty: %v, %v", points, err)
+	}
+	for _, bad := range []string{"0", "0:x", "x:0", "0:0,"} {
+		if _, err := sirdsc.ParseCurve(bad); err == nil {
+			t.Errorf("parsed %q without an error", bad)
+		}
+	}
+}
+
+func TestImageDepthMapAutoLevels(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 1))
+	img.Pix = []uint8{64, 96, 128, 192}
+
+	dm := sirdsc.ImageDepthMap{Image: img, Max: 32}
+	dm.Transfer = dm.AutoLevels()
+
+	want := []float64{0, 8, 16, 32}
+	for x, want := range want {
+		if got := dm.AtF(x, 0); math.Abs(got-want) > 1e-9 {
+			t.Errorf("AtF(%v, 0) == %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestImageDepthMapEqualize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	for x, v := range []uint8{10, 11, 12, 13, 250} {
+		img.SetNRGBA(x, 0, color.NRGBA{v, v, v, 0xff})
+	}
+
+	dm := sirdsc.ImageDepthMap{Image: img, Max: 40}
+	dm.Transfer = dm.Equalize()
+
+	want := []float64{0, 10, 20, 30, 40}
+	for x, want := range want {
+		if got := dm.AtF(x, 0); math.Abs(got-want) > 1e-9 {
+			t.Errorf("AtF(%v, 0) == %v, want %v", x, got, want)
+		}
+	}
+}